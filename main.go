@@ -1,13 +1,11 @@
 package main
 
 import (
-	"encoding/json"
 	"flag"
 	"fmt"
 	"gomulus"
-	destinations "gomulus/destination"
-	sources "gomulus/source"
-	"io/ioutil"
+	_ "gomulus/destination"
+	_ "gomulus/source"
 	"log"
 	"math"
 	"os"
@@ -19,7 +17,7 @@ import (
 	"time"
 )
 
-var FlagConfig = flag.String("config", "./config/config.json", "JSON config file path")
+var FlagConfig = flag.String("config", "./config/config.json", "JSON, YAML or TOML config file path")
 
 var SourceInstance gomulus.SourceInterface
 
@@ -27,151 +25,225 @@ var DestinationInstance gomulus.DestinationInterface
 
 var FetchPool map[int]chan map[string]interface{}
 
-var PersistPool map[int]chan [][]interface{}
+var PersistPool map[int]chan Task
 
 var FetchChannelLength = 1000
 
 var PersistChannelLength = 1000
 
+var StreamChannel chan Stream
+
+var ChunkSize = 1000
+
 var PendingJobsCount int64
 
+var GeneratingJobs int32
+
+var FailedJobsCount int64
+
+// Task is a batch waiting to be persisted, with the acknowledgement of the job it belongs to.
+type Task struct {
+	Batch gomulus.Batch
+	Ack   *Acknowledgement
+}
+
+// Stream is the channel of the rows of a job streamed to the destination, with its acknowledgement.
+type Stream struct {
+	Rows chan []interface{}
+	Ack  *Acknowledgement
+}
+
+// Acknowledgement counts the fetch and the batches of a job still in progress. The job is
+// acknowledged to sources implementing the AcknowledgerInterface once all of them succeeded,
+// or to the ones implementing the FailureAcknowledgerInterface once any of them failed.
+type Acknowledgement struct {
+	Job     map[string]interface{}
+	Pending int64
+	Failed  int32
+}
+
 func main() {
 
 	var err error
-	var started = time.Now()
+	var config gomulus.Config
 
-	flag.Parse()
+	if len(os.Args) > 1 && os.Args[1] == "copy" {
 
-	var config gomulus.Config
-	var configPath string
-	var configFile *os.File
+		config, err = CopyConfig(os.Args[2:])
 
-	if configPath, err = filepath.Abs(*FlagConfig); err != nil {
-		log.Fatal(err.Error())
-	}
+	} else {
 
-	if configFile, err = os.Open(configPath); err != nil {
-		log.Fatal(err.Error())
-	}
+		flag.Parse()
 
-	configJSON, _ := ioutil.ReadAll(configFile)
+		config, err = FileConfig(*FlagConfig)
 
-	_ = configFile.Close()
+	}
 
-	if err = json.Unmarshal(configJSON, &config); err != nil {
-		log.Fatal(err.Error())
+	if err != nil {
+		log.Fatal(gomulus.Redact(err.Error()))
 	}
 
-	Source := config.Source
-	Destination := config.Destination
+	log.Print("loaded configuration ", config)
 
-	FetchPool = make(map[int]chan map[string]interface{}, 0)
+	Run(config)
 
-	for i := 1; i <= int(math.Max(1, float64(Source.Pool))); i++ {
+}
 
-		FetchPool[i] = make(chan map[string]interface{}, FetchChannelLength)
+func FileConfig(path string) (gomulus.Config, error) {
+
+	var err error
+	var config gomulus.Config
 
+	if path, err = filepath.Abs(path); err != nil {
+		return config, err
 	}
 
-	PersistPool = make(map[int]chan [][]interface{}, 0)
+	if config, err = gomulus.LoadConfig(path); err != nil {
+		return config, err
+	}
 
-	for i := 1; i <= int(math.Max(1, float64(Destination.Pool))); i++ {
+	if err = config.Interpolate(); err != nil {
+		return config, err
+	}
 
-		PersistPool[i] = make(chan [][]interface{}, PersistChannelLength)
+	return config, nil
 
-	}
+}
 
-	log.Print("starting...")
+func CopyConfig(args []string) (gomulus.Config, error) {
 
-	if SourceInstance, DestinationInstance, err = Start(Source, Destination); err != nil {
-		log.Fatal(err.Error())
+	var err error
+	var config gomulus.Config
+	var urls = make([]string, 0)
+
+	flags := flag.NewFlagSet("copy", flag.ExitOnError)
+	sourcePool := flags.Int("source-pool", 1, "number of concurrent source operations")
+	destinationPool := flags.Int("dest-pool", 1, "number of concurrent destination operations")
+	sourcePlugin := flags.String("source-plugin", "", "custom source driver plugin path")
+	destinationPlugin := flags.String("dest-plugin", "", "custom destination driver plugin path")
+	timeout := flags.Int("timeout", 0, "timeout in milliseconds")
+
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s copy [flags] <source-url> <destination-url>\n", os.Args[0])
+		flags.PrintDefaults()
 	}
 
-	go func() {
+	// flags are allowed both before and after the URLs
+	for {
+		_ = flags.Parse(args)
+		if args = flags.Args(); len(args) == 0 {
+			break
+		}
+		urls = append(urls, args[0])
+		args = args[1:]
+	}
 
-		for q, Selection := range FetchPool {
+	if len(urls) != 2 {
+		flags.Usage()
+		return config, fmt.Errorf("expected a source and a destination URL, got %d arguments", len(urls))
+	}
 
-			go func(FetchChannel chan map[string]interface{}, q int) {
+	for _, path := range []string{*sourcePlugin, *destinationPlugin} {
+		if path == "" {
+			continue
+		}
+		if _, err = OpenPlugin(path); err != nil {
+			return config, err
+		}
+	}
 
-				for job := range FetchChannel {
+	if config.Source, err = gomulus.ParseSourceURL(urls[0]); err != nil {
+		return config, err
+	}
 
-					if data, err := SourceInstance.FetchData(job); err != nil {
+	if config.Destination, err = gomulus.ParseDestinationURL(urls[1]); err != nil {
+		return config, err
+	}
 
-						atomic.AddInt64(&PendingJobsCount, -1)
+	config.Timeout = *timeout
+	config.Source.Pool = *sourcePool
+	config.Source.Plugin = *sourcePlugin
+	config.Destination.Pool = *destinationPool
+	config.Destination.Plugin = *destinationPlugin
 
-						log.Print("failed data fetching on queue ", q, "; an error occurred: ", err.Error())
+	return config, nil
 
-					} else {
+}
 
-						if err != nil {
+func Run(config gomulus.Config) {
 
-							atomic.AddInt64(&PendingJobsCount, -1)
+	var err error
+	var started = time.Now()
 
-							log.Print("failed data pre-processing on queue ", q, "; an error occurred: ", err.Error())
+	Source := config.Source
+	Destination := config.Destination
 
-						} else {
+	FetchPool = make(map[int]chan map[string]interface{}, 0)
 
-							queue := 0
+	for i := 1; i <= int(math.Max(1, float64(Source.Pool))); i++ {
 
-							for true {
+		FetchPool[i] = make(chan map[string]interface{}, FetchChannelLength)
 
-								lengths := make(map[int]int, 0)
+	}
 
-								for id, queue := range PersistPool {
-									lengths[id] = len(queue)
-								}
+	PersistPool = make(map[int]chan Task, 0)
 
-								queue = GetShortestQueue(lengths)
+	for i := 1; i <= int(math.Max(1, float64(Destination.Pool))); i++ {
 
-								if len(PersistPool[queue]) <= 0 || len(PersistPool[queue]) < PersistChannelLength {
-									break
-								}
+		PersistPool[i] = make(chan Task, PersistChannelLength)
 
-								time.Sleep(time.Millisecond * 500)
+	}
 
-							}
+	if config.ChunkSize > 0 {
+		ChunkSize = config.ChunkSize
+	}
 
-							PersistPool[queue] <- data
+	log.Print("starting...")
 
-							log.Print("fetching ", len(data), " rows on queue ", q, "...")
+	if SourceInstance, DestinationInstance, err = Start(Source, Destination); err != nil {
+		log.Fatal(gomulus.Redact(err.Error()))
+	}
 
-						}
+	_, streamingSource := SourceInstance.(gomulus.StreamingSourceInterface)
+	_, streamingDestination := DestinationInstance.(gomulus.StreamingDestinationInterface)
 
-					}
+	if batchOnly, ok := DestinationInstance.(gomulus.BatchOnlyDestinationInterface); ok && batchOnly.BatchOnly() {
+		streamingDestination = false
+	}
 
-				}
+	if streamingSource && streamingDestination {
 
-			}(Selection, q)
+		log.Print("streaming rows in chunks of ", ChunkSize, "...")
 
-		}
+		StreamChannel = make(chan Stream)
 
-	}()
+	}
 
 	go func() {
 
-		for q, concurrentInsert := range PersistPool {
+		for q, Selection := range FetchPool {
 
-			go func(PersistChannel chan [][]interface{}, q int) {
+			go FetchWorker(Selection, q)
 
-				for data := range PersistChannel {
+		}
 
-					log.Print("fetched ", len(data), " rows on queue ", q, "...")
+	}()
 
-					atomic.AddInt64(&PendingJobsCount, -1)
+	go func() {
 
-					if n, err := DestinationInstance.PersistData(data); err != nil {
+		for q, concurrentInsert := range PersistPool {
 
-						log.Print("failed data persist on queue ", q, "; lost ", n, ", an error occurred: ", err.Error())
+			go PersistWorker(concurrentInsert, q)
 
-					} else {
+		}
 
-						log.Print("persisted ", n, " rows on queue ", q)
+		if StreamChannel != nil {
 
-					}
+			for q := range PersistPool {
 
-				}
+				go StreamWorker(StreamChannel, q)
 
-			}(concurrentInsert, q)
+			}
 
 		}
 
@@ -187,16 +259,21 @@ func main() {
 	timeTimer := time.NewTimer(time.Second)
 	timeOut := int(config.Timeout / 1000)
 
+	finished := make(chan bool, 1)
+
 	go func() {
 		for {
 			select {
 			case <-timeTimer.C:
 				timeElapsed++
 				if timeOut > 0 && timeElapsed > timeOut {
-					log.Fatal(fmt.Sprintf("timed out after %d seconds", timeOut))
+					log.Print(fmt.Sprintf("timed out after %d seconds", timeOut))
+					_ = Close(false)
+					os.Exit(1)
 				}
-				if atomic.LoadInt64(&PendingJobsCount) == 0 {
-					sigterm <- syscall.SIGINT
+				if atomic.LoadInt32(&GeneratingJobs) == 0 && atomic.LoadInt64(&PendingJobsCount) == 0 {
+					finished <- true
+					return
 				}
 				timeTimer.Reset(time.Second)
 				break
@@ -204,10 +281,31 @@ func main() {
 		}
 	}()
 
-	<-sigterm
+	success := false
+
+	select {
+	case <-sigterm:
+		log.Print("interrupted...")
+	case <-finished:
+		success = atomic.LoadInt64(&FailedJobsCount) == 0
+	}
+
+	if !success {
+		log.Print("run not completed, ", atomic.LoadInt64(&FailedJobsCount), " failures occurred")
+	}
+
+	if err = Close(success); err != nil {
+		log.Print("failed closing drivers; an error occurred: ", gomulus.Redact(err.Error()))
+		success = false
+	}
 
 	log.Print("DONE, took ", time.Now().Unix()-started.Unix(), " seconds")
 
+	// failed or interrupted runs exit with an error, so that schedulers can retry them
+	if !success {
+		os.Exit(1)
+	}
+
 	os.Exit(0)
 
 }
@@ -215,145 +313,564 @@ func main() {
 func Start(Source gomulus.DriverConfig, Destination gomulus.DriverConfig) (gomulus.SourceInterface, gomulus.DestinationInterface, error) {
 
 	var err error
-	var found bool
 	var source gomulus.SourceInterface
 	var destination gomulus.DestinationInterface
 
-	err = nil
-	found = false
+	if source, err = NewSource(Source); err != nil {
+		return nil, nil, err
+	}
 
-	switch Source.Driver {
+	if destination, err = NewDestination(Destination); err != nil {
+		return nil, nil, err
+	}
 
-	case "csv":
+	log.Print(fmt.Sprintf("starting a new `%s` source driver instance...", Source.Driver))
 
-		source = &sources.DefaultCSVSource{}
-		found = true
+	if configurable, ok := source.(gomulus.ConfigurableInterface); ok {
+		configurable.SetConfig(Source)
+	}
 
-	case "mysql":
+	if configurable, ok := destination.(gomulus.ConfigurableInterface); ok {
+		configurable.SetConfig(Destination)
+	}
 
-		source = &sources.DefaultMysqlSource{}
-		found = true
+	if err = source.New(Source.Options); err != nil {
+		return nil, nil, err
+	}
 
-	default:
+	if err = TransferSchema(source, destination); err != nil {
+		return nil, nil, err
+	}
+
+	log.Print(fmt.Sprintf("starting a new `%s` destination driver instance...", Destination.Driver))
+
+	if err = destination.New(Destination.Options); err != nil {
+		return nil, nil, err
+	}
+
+	if err = CheckDeterministicJobs(source, destination); err != nil {
+		return nil, nil, err
+	}
+
+	atomic.StoreInt32(&GeneratingJobs, 1)
+
+	if iterator, ok := source.(gomulus.JobIteratorSourceInterface); ok {
+
+		log.Print("generating source driver jobs...")
+
+		jobs := make(chan map[string]interface{}, FetchChannelLength)
+
+		go func() {
+
+			if err := iterator.IterateJobs(jobs); err != nil {
+				atomic.AddInt64(&FailedJobsCount, 1)
+				log.Print("failed jobs generation; an error occurred: ", gomulus.Redact(err.Error()))
+			}
+
+			close(jobs)
+
+		}()
+
+		go func() {
+
+			count := 0
+
+			for job := range jobs {
+				Dispatch(job)
+				count++
+			}
+
+			log.Print(fmt.Sprintf("generated %d source driver jobs", count))
+
+			atomic.StoreInt32(&GeneratingJobs, 0)
+
+		}()
+
+	} else {
+
+		log.Print(fmt.Sprintf("getting source driver jobs..."))
+
+		jobs, err := source.GetJobs()
 
-		pluginPath, err := filepath.Abs(Source.Plugin)
-		if err != nil {
-			break
-		}
-		plug, err := plugin.Open(pluginPath)
 		if err != nil {
-			break
+			return nil, nil, err
 		}
-		symbol, err := plug.Lookup(Source.Driver)
+
+		log.Print(fmt.Sprintf("processing %d source driver jobs...", len(jobs)))
+
+		go func() {
+
+			for _, job := range jobs {
+				Dispatch(job)
+			}
+
+			atomic.StoreInt32(&GeneratingJobs, 0)
+
+		}()
+
+	}
+
+	return source, destination, nil
+
+}
+
+// Dispatch sends a job to the shortest fetch queue, waiting when all of them are full.
+func Dispatch(job map[string]interface{}) {
+
+	atomic.AddInt64(&PendingJobsCount, 1)
+
+	lengths := make(map[int]int, 0)
+
+	for id, queue := range FetchPool {
+		lengths[id] = len(queue)
+	}
+
+	FetchPool[GetShortestQueue(lengths)] <- job
+
+}
+
+func FetchWorker(FetchChannel chan map[string]interface{}, q int) {
+
+	for job := range FetchChannel {
+
+		ack := NewAcknowledgement(job)
+
+		err := Fetch(job, ack, q)
+
+		ack.Done(err)
+
 		if err != nil {
-			break
+
+			atomic.AddInt64(&FailedJobsCount, 1)
+
+			log.Print("failed data fetching on queue ", q, "; an error occurred: ", gomulus.Redact(err.Error()))
+
 		}
-		var ok bool
-		source, ok = symbol.(gomulus.SourceInterface)
-		if !ok {
-			break
+
+		atomic.AddInt64(&PendingJobsCount, -1)
+
+	}
+
+}
+
+// Fetch runs a job, preferring the streaming interfaces when implemented by the drivers.
+// Without a streaming destination, or when the job reads one of several tables,
+// streamed rows are enqueued in batches of ChunkSize rows.
+func Fetch(job map[string]interface{}, ack *Acknowledgement, q int) error {
+
+	batch, err := NewBatch(job)
+
+	if err != nil {
+		return err
+	}
+
+	source, ok := SourceInstance.(gomulus.StreamingSourceInterface)
+
+	if !ok {
+
+		if batch.Data, err = SourceInstance.FetchData(job); err != nil {
+			return err
 		}
 
-		found = true
+		return Enqueue(batch, ack, q)
+
+	}
+
+	rows := make(chan []interface{}, ChunkSize)
+	done := make(chan error, 1)
+
+	if StreamChannel != nil && batch.Table == "" {
+
+		atomic.AddInt64(&PendingJobsCount, 1)
+
+		ack.Add()
+
+		StreamChannel <- Stream{Rows: rows, Ack: ack}
+
+		log.Print("streaming rows on queue ", q, "...")
+
+		close(done)
+
+	} else {
+
+		go func() {
+
+			var err error
+
+			chunk := batch
+			chunk.Data = make([][]interface{}, 0, ChunkSize)
+
+			for row := range rows {
+				chunk.Data = append(chunk.Data, row)
+				if len(chunk.Data) >= ChunkSize && err == nil {
+					err = Enqueue(chunk, ack, q)
+					chunk.Chunk++
+					chunk.Data = make([][]interface{}, 0, ChunkSize)
+				}
+			}
+
+			if len(chunk.Data) > 0 && err == nil {
+				err = Enqueue(chunk, ack, q)
+			}
+
+			done <- err
+
+		}()
 
 	}
 
-	if !found {
+	err = source.StreamData(job, rows)
 
-		return nil, nil, fmt.Errorf("no source driver found under the name `%s`: %s", Source.Driver, err.Error())
+	close(rows)
 
+	if e := <-done; e != nil && err == nil {
+		err = e
 	}
 
-	err = nil
-	found = false
+	return err
 
-	switch Destination.Driver {
+}
 
-	case "csv":
+// NewBatch tags the rows of a job with the table it reads, and its schema, when the source exports several tables.
+func NewBatch(job map[string]interface{}) (gomulus.Batch, error) {
 
-		destination = &destinations.DefaultCSVDestination{}
-		found = true
+	var err error
+	var batch = gomulus.Batch{Job: job}
 
-	case "mysql":
+	if batch.Table, _ = job["table"].(string); batch.Table == "" {
+		return batch, nil
+	}
 
-		destination = &destinations.DefaultMysqlDestination{}
-		found = true
+	if _, ok := DestinationInstance.(gomulus.BatchDestinationInterface); !ok {
+		return batch, fmt.Errorf("destination driver does not support several tables, unable to persist table `%s`", batch.Table)
+	}
 
-	default:
+	if source, ok := SourceInstance.(gomulus.TableSourceInterface); ok {
+		if batch.Schema, err = source.GetTableSchema(batch.Table); err != nil {
+			return batch, err
+		}
+	}
 
-		pluginPath, err := filepath.Abs(Destination.Plugin)
-		if err != nil {
+	return batch, nil
+
+}
+
+func Enqueue(batch gomulus.Batch, ack *Acknowledgement, q int) error {
+
+	var err error
+
+	if batch.Data, err = DestinationInstance.PreProcessData(batch.Data); err != nil {
+		return fmt.Errorf("failed data pre-processing: %s", err.Error())
+	}
+
+	queue := 0
+
+	for true {
+
+		lengths := make(map[int]int, 0)
+
+		for id, queue := range PersistPool {
+			lengths[id] = len(queue)
+		}
+
+		queue = GetShortestQueue(lengths)
+
+		if len(PersistPool[queue]) <= 0 || len(PersistPool[queue]) < PersistChannelLength {
 			break
 		}
-		plug, err := plugin.Open(pluginPath)
+
+		time.Sleep(time.Millisecond * 500)
+
+	}
+
+	atomic.AddInt64(&PendingJobsCount, 1)
+
+	ack.Add()
+
+	PersistPool[queue] <- Task{Batch: batch, Ack: ack}
+
+	log.Print("fetching ", len(batch.Data), " rows on queue ", q, "...")
+
+	return nil
+
+}
+
+func PersistWorker(PersistChannel chan Task, q int) {
+
+	for task := range PersistChannel {
+
+		log.Print("fetched ", len(task.Batch.Data), " rows on queue ", q, "...")
+
+		n, err := Persist(task.Batch)
+
+		task.Ack.Done(err)
+
 		if err != nil {
-			break
+
+			atomic.AddInt64(&FailedJobsCount, 1)
+
+			log.Print("failed data persist on queue ", q, "; persisted ", n, " of ", len(task.Batch.Data), " rows, an error occurred: ", gomulus.Redact(err.Error()))
+
+		} else {
+
+			log.Print("persisted ", n, " rows on queue ", q)
+
 		}
-		symbol, err := plug.Lookup(Destination.Driver)
+
+		atomic.AddInt64(&PendingJobsCount, -1)
+
+	}
+
+}
+
+func NewAcknowledgement(job map[string]interface{}) *Acknowledgement {
+
+	if _, ok := SourceInstance.(gomulus.AcknowledgerInterface); !ok {
+		return nil
+	}
+
+	return &Acknowledgement{Job: job, Pending: 1}
+
+}
+
+func (a *Acknowledgement) Add() {
+
+	if a != nil {
+		atomic.AddInt64(&a.Pending, 1)
+	}
+
+}
+
+// Done marks the fetch or a batch of the job as completed, acknowledging the job after the last one.
+func (a *Acknowledgement) Done(err error) {
+
+	if a == nil {
+		return
+	}
+
+	if err != nil {
+		atomic.StoreInt32(&a.Failed, 1)
+	}
+
+	if atomic.AddInt64(&a.Pending, -1) > 0 {
+		return
+	}
+
+	if atomic.LoadInt32(&a.Failed) == 1 {
+		if source, ok := SourceInstance.(gomulus.FailureAcknowledgerInterface); ok {
+			source.AcknowledgeFailure(a.Job)
+		}
+		return
+	}
+
+	if err := SourceInstance.(gomulus.AcknowledgerInterface).Acknowledge(a.Job); err != nil {
+
+		atomic.AddInt64(&FailedJobsCount, 1)
+
+		log.Print("failed job acknowledgement; an error occurred: ", gomulus.Redact(err.Error()))
+
+	}
+
+}
+
+// Persist hands the batch to destinations supporting tables, or its rows to the others.
+func Persist(batch gomulus.Batch) (int, error) {
+
+	if destination, ok := DestinationInstance.(gomulus.BatchDestinationInterface); ok {
+		return destination.PersistBatch(batch)
+	}
+
+	return DestinationInstance.PersistData(batch.Data)
+
+}
+
+func StreamWorker(StreamChannel chan Stream, q int) {
+
+	destination := DestinationInstance.(gomulus.StreamingDestinationInterface)
+
+	for stream := range StreamChannel {
+
+		n, err := destination.PersistStream(stream.Rows)
+
+		// unblock the source when the destination gave up early
+		for range stream.Rows {
+		}
+
+		stream.Ack.Done(err)
+
 		if err != nil {
-			break
+
+			atomic.AddInt64(&FailedJobsCount, 1)
+
+			log.Print("failed stream persist on queue ", q, "; persisted ", n, " rows, an error occurred: ", gomulus.Redact(err.Error()))
+
+		} else {
+
+			log.Print("persisted ", n, " streamed rows on queue ", q)
+
 		}
-		var ok bool
-		destination, ok = symbol.(gomulus.DestinationInterface)
-		if !ok {
-			break
+
+		atomic.AddInt64(&PendingJobsCount, -1)
+
+	}
+
+}
+
+// Close runs the drivers close hooks, the destination first, so that the source
+// only commits its own state once data has been persisted.
+func Close(success bool) error {
+
+	var err error
+
+	if closer, ok := DestinationInstance.(gomulus.CloserInterface); ok {
+		if err = closer.Close(success); err != nil {
+			success = false
 		}
+	}
+
+	if closer, ok := SourceInstance.(gomulus.CloserInterface); ok {
+		if e := closer.Close(success); e != nil && err == nil {
+			err = e
+		}
+	}
+
+	return err
+
+}
 
-		found = true
+// TransferSchema hands the source schema to the destination, before the latter is started.
+func TransferSchema(source gomulus.SourceInterface, destination gomulus.DestinationInterface) error {
 
+	var err error
+	var schema gomulus.Schema
+
+	schemaSource, ok := source.(gomulus.SchemaSourceInterface)
+
+	if !ok {
+		return nil
 	}
 
-	if !found {
-		return nil, nil, fmt.Errorf("no destination driver found under the name `%s`: %s", Destination.Driver, err.Error())
+	schemaDestination, ok := destination.(gomulus.SchemaDestinationInterface)
+
+	if !ok {
+		return nil
 	}
 
-	log.Print(fmt.Sprintf("starting a new `%s` source driver instance...", Source.Driver))
+	if schema, err = schemaSource.GetSchema(); err != nil {
+		return fmt.Errorf("unable to get source schema: %s", err.Error())
+	}
 
-	if err = source.New(Source.Options); err != nil {
-		return nil, nil, err
+	if len(schema.Columns) == 0 {
+		return nil
 	}
 
-	log.Print(fmt.Sprintf("starting a new `%s` destination driver instance...", Destination.Driver))
+	log.Print(fmt.Sprintf("transferring a %d columns source schema...", len(schema.Columns)))
 
-	if err = destination.New(Destination.Options); err != nil {
-		return nil, nil, err
+	return schemaDestination.SetSchema(schema)
+
+}
+
+// CheckDeterministicJobs fails when the destination identifies the rows by their job, e.g. with
+// the ledger, while the jobs of the source may select different rows on every run.
+func CheckDeterministicJobs(source gomulus.SourceInterface, destination gomulus.DestinationInterface) error {
+
+	requiring, ok := destination.(gomulus.DeterministicJobsDestinationInterface)
+
+	if !ok || !requiring.RequiresDeterministicJobs() {
+		return nil
+	}
+
+	if deterministic, ok := source.(gomulus.DeterministicJobsSourceInterface); ok && !deterministic.DeterministicJobs() {
+		return fmt.Errorf("the destination requires jobs selecting the same rows on every run, set split_by or read tables with a primary key")
 	}
 
-	log.Print(fmt.Sprintf("getting source driver jobs..."))
+	return nil
 
-	jobs, err := source.GetJobs()
+}
 
-	lengths := make(map[int]int, 0)
+func NewSource(config gomulus.DriverConfig) (gomulus.SourceInterface, error) {
 
-	for id, queue := range FetchPool {
-		lengths[id] = len(queue)
+	if source, ok := gomulus.NewSource(config.Driver); ok {
+		return source, nil
 	}
 
-	log.Print(fmt.Sprintf("processing %d source driver jobs...", len(jobs)))
+	if config.Plugin == "" {
+		return nil, fmt.Errorf("no source driver found under the name `%s`", config.Driver)
+	}
 
-	go func() {
+	plug, err := OpenPlugin(config.Plugin)
 
-		for _, job := range jobs {
+	if err != nil {
+		return nil, fmt.Errorf("no source driver found under the name `%s`: %s", config.Driver, err.Error())
+	}
 
-			atomic.AddInt64(&PendingJobsCount, 1)
+	// the plugin may have registered the driver while being opened
+	if source, ok := gomulus.NewSource(config.Driver); ok {
+		return source, nil
+	}
 
-			queue := 0
+	symbol, err := plug.Lookup(config.Driver)
 
-			lengths := make(map[int]int, 0)
+	if err != nil {
+		return nil, fmt.Errorf("no source driver found under the name `%s`: %s", config.Driver, err.Error())
+	}
 
-			for id, queue := range FetchPool {
-				lengths[id] = len(queue)
-			}
+	source, ok := symbol.(gomulus.SourceInterface)
 
-			queue = GetShortestQueue(lengths)
+	if !ok {
+		return nil, fmt.Errorf("no source driver found under the name `%s`: symbol does not implement SourceInterface", config.Driver)
+	}
 
-			FetchPool[queue] <- job
+	return source, nil
 
-		}
+}
 
-	}()
+func NewDestination(config gomulus.DriverConfig) (gomulus.DestinationInterface, error) {
 
-	return source, destination, nil
+	if destination, ok := gomulus.NewDestination(config.Driver); ok {
+		return destination, nil
+	}
+
+	if config.Plugin == "" {
+		return nil, fmt.Errorf("no destination driver found under the name `%s`", config.Driver)
+	}
+
+	plug, err := OpenPlugin(config.Plugin)
+
+	if err != nil {
+		return nil, fmt.Errorf("no destination driver found under the name `%s`: %s", config.Driver, err.Error())
+	}
+
+	// the plugin may have registered the driver while being opened
+	if destination, ok := gomulus.NewDestination(config.Driver); ok {
+		return destination, nil
+	}
+
+	symbol, err := plug.Lookup(config.Driver)
+
+	if err != nil {
+		return nil, fmt.Errorf("no destination driver found under the name `%s`: %s", config.Driver, err.Error())
+	}
+
+	destination, ok := symbol.(gomulus.DestinationInterface)
+
+	if !ok {
+		return nil, fmt.Errorf("no destination driver found under the name `%s`: symbol does not implement DestinationInterface", config.Driver)
+	}
+
+	return destination, nil
+
+}
+
+func OpenPlugin(path string) (*plugin.Plugin, error) {
+
+	var err error
+
+	if path, err = filepath.Abs(path); err != nil {
+		return nil, err
+	}
+
+	return plugin.Open(path)
 
 }
 
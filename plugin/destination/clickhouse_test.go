@@ -0,0 +1,75 @@
+package main
+
+import (
+	"gomulus"
+	"reflect"
+	"testing"
+)
+
+func TestConvertDecimal(t *testing.T) {
+
+	tests := []struct {
+		name       string
+		value      interface{}
+		columnType string
+		expected   interface{}
+		fails      bool
+	}{
+		{"Decimal32 from text", "-12.34", "Decimal(9, 2)", int32(-1234), false},
+		{"Decimal32 truncated", []byte("1.239"), "Decimal(9, 2)", int32(123), false},
+		{"Decimal32 overflow", "10000000", "Decimal(9, 2)", nil, true},
+		{"Decimal64 from float", 0.1, "Decimal(18, 4)", int64(1000), false},
+		{"Decimal64 from int", int64(-3), "Decimal(18,0)", int64(-3), false},
+		{"Decimal128", "1234567890123456789.5", "Decimal(38, 1)", []byte{0xd7, 0x0a, 0x1f, 0xeb, 0x8c, 0xa9, 0x54, 0xab, 0, 0, 0, 0, 0, 0, 0, 0}, false},
+		{"Decimal128 negative", "-1", "Decimal(20, 0)", []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, false},
+		{"nullable", "2.5", "Nullable(Decimal(9, 1))", int32(25), false},
+		{"nullable NULL", nil, "Nullable(Decimal(9, 1))", nil, false},
+		{"NULL of a non nullable column", nil, "Decimal(9, 1)", int32(0), false},
+		{"empty text", "", "Decimal(20, 2)", make([]byte, 16), false},
+		{"precision beyond the driver", "1", "Decimal(65, 30)", nil, true},
+		{"unparseable", "1.2.3", "Decimal(9, 2)", nil, true},
+	}
+
+	for _, test := range tests {
+
+		value, err := convertValue(test.value, test.columnType)
+
+		if test.fails {
+			if err == nil {
+				t.Errorf("%s: expected an error, got %v", test.name, value)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", test.name, err.Error())
+			continue
+		}
+
+		if !reflect.DeepEqual(value, test.expected) {
+			t.Errorf("%s: expected %#v, got %#v", test.name, test.expected, value)
+		}
+
+	}
+
+}
+
+func TestSchemaColumnsDecimal(t *testing.T) {
+
+	schema := gomulus.Schema{Columns: []gomulus.Column{
+		{Name: "price", Type: gomulus.TypeDecimal, Precision: 10, Scale: 2},
+		{Name: "amount", Type: gomulus.TypeDecimal, Precision: 65, Scale: 30, Nullable: true},
+		{Name: "ratio", Type: gomulus.TypeDecimal},
+	}}
+
+	expected := []interface{}{
+		map[string]interface{}{"price": "Decimal(10, 2)"},
+		map[string]interface{}{"amount": "Nullable(String)"},
+		map[string]interface{}{"ratio": "String"},
+	}
+
+	if columns := schemaColumns(schema, nil); !reflect.DeepEqual(columns, expected) {
+		t.Errorf("expected %v, got %v", expected, columns)
+	}
+
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"gomulus"
+	source "gomulus/source"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// testdata/binlog.000001 is a row-based binary log of the `shop` database, each statement in
+// its own transaction, with CRC32 checksums:
+//
+//	CREATE TABLE orders (id INT NOT NULL, note VARCHAR(20) NULL);
+//	CREATE TABLE logs (id INT NOT NULL);
+//	INSERT INTO orders VALUES (1, 'first'), (2, NULL);  -- committed at 305
+//	UPDATE orders SET note = 'second' WHERE id = 1;      -- committed at 493
+//	DELETE FROM orders WHERE id = 2;                     -- committed at 662
+//	INSERT INTO logs VALUES (7);                         -- committed at 826, the end of the file
+func TestBinlogFile(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "gomulus-binlog")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	var (
+		inserted = [][]interface{}{{"insert", int64(1), "first"}, {"insert", int64(2), nil}}
+		updated  = [][]interface{}{{"update", int64(1), "second"}}
+		deleted  = [][]interface{}{{"delete", int64(2), nil}}
+	)
+
+	tests := []struct {
+		name       string
+		checkpoint string
+		tables     interface{}
+		limit      float64
+		jobs       int
+		rows       [][]interface{}
+		resume     string
+	}{
+		{"whole file", "", "orders", 100, 1, concatRows(inserted, updated, deleted), "binlog.000001:826"},
+		{"job per row", "", "orders", 1, 4, concatRows(inserted, updated, deleted), "binlog.000001:493"},
+		{"resume after the update", "binlog.000001:493", "orders", 100, 1, deleted, "binlog.000001:826"},
+		{"resume at the end", "binlog.000001:826", "orders", 100, 0, nil, "binlog.000001:826"},
+		{"checkpoint of another file", "binlog.000000:4", "orders", 100, 1, concatRows(inserted, updated, deleted), "binlog.000001:826"},
+		{"every table", "", []interface{}{"*"}, 100, 2, concatRows(inserted, updated, deleted, [][]interface{}{{"insert", int64(7)}}), "binlog.000001:826"},
+	}
+
+	for _, test := range tests {
+
+		t.Run(test.name, func(t *testing.T) {
+
+			state := filepath.Join(dir, "state.json")
+
+			if test.checkpoint != "" {
+				if err := gomulus.SaveState(state, test.name, test.checkpoint); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			s := &binlogSource{}
+
+			err := s.New(map[string]interface{}{
+				"binlog_file": filepath.Join("testdata", "binlog.000001"),
+				"database":    "shop",
+				"tables":      test.tables,
+				"limit":       test.limit,
+				"state_file":  state,
+				"state_key":   test.name,
+			})
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			jobs, err := gomulus.CollectJobs(s.IterateJobs)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(jobs) != test.jobs {
+				t.Errorf("expected %d jobs, got %d", test.jobs, len(jobs))
+			}
+
+			rows := make([][]interface{}, 0)
+
+			for _, job := range jobs {
+
+				data, err := s.FetchData(job)
+
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				rows = append(rows, data...)
+
+				if err = s.Acknowledge(job); err != nil {
+					t.Fatal(err)
+				}
+
+			}
+
+			if len(rows) != len(test.rows) || (len(rows) > 0 && !reflect.DeepEqual(rows, test.rows)) {
+				t.Errorf("expected rows %v, got %v", test.rows, rows)
+			}
+
+			if checkpoint, _ := gomulus.LoadState(state, test.name); checkpoint != test.resume {
+				t.Errorf("expected checkpoint `%s`, got `%s`", test.resume, checkpoint)
+			}
+
+		})
+
+	}
+
+}
+
+func TestBinlogFileSchema(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "gomulus-binlog")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	s := &binlogSource{}
+
+	err = s.New(map[string]interface{}{
+		"binlog_file": filepath.Join("testdata", "binlog.000001"),
+		"database":    "shop",
+		"op_column":   "change",
+		"state_file":  filepath.Join(dir, "state.json"),
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = gomulus.CollectJobs(s.IterateJobs); err != nil {
+		t.Fatal(err)
+	}
+
+	schema, err := s.GetTableSchema("orders")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// without a server the columns are named after their position
+	expected := []gomulus.Column{
+		{Name: "change", Type: gomulus.TypeString},
+		{Name: "column_1", Type: gomulus.TypeInt, Nullable: true},
+		{Name: "column_2", Type: gomulus.TypeString, Nullable: true},
+	}
+
+	if !reflect.DeepEqual(schema.Columns, expected) {
+		t.Errorf("expected columns %v, got %v", expected, schema.Columns)
+	}
+
+}
+
+func concatRows(lists ...[][]interface{}) [][]interface{} {
+
+	rows := make([][]interface{}, 0)
+
+	for _, list := range lists {
+		rows = append(rows, list...)
+	}
+
+	return rows
+
+}
+
+// A run stopped before every snapshot job is persisted must take the snapshot again,
+// the checkpoint being saved only once the snapshot is complete.
+func TestBinlogSnapshotCheckpoint(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "gomulus-binlog")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	state := filepath.Join(dir, "state.json")
+	start := "binlog.000001:305"
+
+	run := func(persisted int) {
+
+		s := &binlogSource{
+			Snapshot:     &source.DefaultMysqlSource{},
+			StateFile:    state,
+			StateKey:     "snapshot",
+			Start:        start,
+			Positions:    make(map[int64]string, 0),
+			Acknowledged: make(map[int64]bool, 0),
+		}
+
+		jobs := make([]map[string]interface{}, 0)
+
+		for i := 0; i < 3; i++ {
+			jobs = append(jobs, map[string]interface{}{"snapshot": true, "sequence": s.sequence(s.Start)})
+		}
+
+		s.SnapshotEnd = s.Sequence
+		s.SnapshotDone = true
+
+		for _, job := range jobs[:persisted] {
+			if err := s.Acknowledge(job); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+	}
+
+	for persisted := 0; persisted < 3; persisted++ {
+
+		run(persisted)
+
+		if checkpoint, _ := gomulus.LoadState(state, "snapshot"); checkpoint != "" {
+			t.Fatalf("%d snapshot jobs persisted: expected no checkpoint to resume from, got `%s`", persisted, checkpoint)
+		}
+
+	}
+
+	run(3)
+
+	if checkpoint, _ := gomulus.LoadState(state, "snapshot"); checkpoint != start {
+		t.Errorf("expected checkpoint `%s` once the snapshot is persisted, got `%s`", start, checkpoint)
+	}
+
+}
@@ -0,0 +1,11 @@
+package gomulus
+
+// Batch is a chunk of the rows fetched by a source job, tagged with the table
+// they were read from when the source exports several tables.
+type Batch struct {
+	Table  string
+	Schema Schema
+	Job    map[string]interface{}
+	Chunk  int
+	Data   [][]interface{}
+}
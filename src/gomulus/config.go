@@ -2,6 +2,7 @@ package gomulus
 
 type Config struct {
 	Timeout     int          `json:"timeout,omitempty"`
+	ChunkSize   int          `json:"chunk_size,omitempty"`
 	Source      DriverConfig `json:"source"`
 	Destination DriverConfig `json:"destination"`
 }
@@ -12,3 +13,35 @@ type DriverConfig struct {
 	Options map[string]interface{} `json:"options,omitempty"`
 	Pool    int                    `json:"pool,omitempty"`
 }
+
+// StringList reads an option given either as a single string or as a list of strings.
+func StringList(value interface{}) []string {
+
+	var list = make([]string, 0)
+
+	switch v := value.(type) {
+	case string:
+		list = append(list, v)
+	case []interface{}:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+	}
+
+	return list
+
+}
+
+func InSliceString(a string, list []string) bool {
+
+	for _, b := range list {
+		if b == a {
+			return true
+		}
+	}
+
+	return false
+
+}
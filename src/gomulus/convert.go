@@ -0,0 +1,522 @@
+package gomulus
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const DateLayout = "2006-01-02"
+
+const DatetimeLayout = "2006-01-02 15:04:05.999999999"
+
+var timeLayouts = []string{
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02T15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999 -0700 MST",
+	DatetimeLayout,
+	"2006-01-02T15:04:05.999999999",
+	DateLayout,
+}
+
+// Convert returns the canonical representation of a value for the given logical type:
+// int64, uint64, float64, decimal string, string, []byte, bool, time.Time,
+// json.RawMessage, or nil for NULL.
+func Convert(value interface{}, t Type) (interface{}, error) {
+
+	return ConvertIn(value, t, time.UTC)
+
+}
+
+// ConvertColumn converts a value following the column logical type and timezone.
+func ConvertColumn(value interface{}, column Column) (interface{}, error) {
+
+	location, err := column.Location()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ConvertIn(value, column.Type, location)
+
+}
+
+// ConvertRow converts every value of a row following the schema columns in the same position.
+// The row is converted in place and returned, values beyond the schema are left as they are.
+func ConvertRow(row []interface{}, schema Schema) ([]interface{}, error) {
+
+	var err error
+
+	for i := range row {
+
+		if i >= len(schema.Columns) {
+			break
+		}
+
+		if row[i], err = ConvertColumn(row[i], schema.Columns[i]); err != nil {
+			return nil, fmt.Errorf("column `%s`: %s", schema.Columns[i].Name, err.Error())
+		}
+
+	}
+
+	return row, nil
+
+}
+
+// ConvertIn converts a value, interpreting dates and times without an explicit timezone in the given location.
+func ConvertIn(value interface{}, t Type, location *time.Location) (interface{}, error) {
+
+	if IsNull(value) {
+		return nil, nil
+	}
+
+	switch t {
+	case TypeInt:
+		return ToInt(value)
+	case TypeUint:
+		return ToUint(value)
+	case TypeFloat:
+		return ToFloat(value)
+	case TypeDecimal:
+		return ToDecimal(value)
+	case TypeBytes:
+		return ToBytes(value)
+	case TypeBool:
+		return ToBool(value)
+	case TypeDate:
+		v, err := ToTime(value, location)
+		if err != nil || v == nil {
+			return v, err
+		}
+		year, month, day := v.(time.Time).Date()
+		return time.Date(year, month, day, 0, 0, 0, 0, time.UTC), nil
+	case TypeDatetime:
+		return ToTime(value, location)
+	case TypeJSON:
+		return ToJSON(value)
+	case TypeNull:
+		return nil, nil
+	case TypeString, "":
+		return ToString(value)
+	}
+
+	return nil, fmt.Errorf("unknown logical type `%s`", t)
+
+}
+
+func IsNull(value interface{}) bool {
+
+	switch v := value.(type) {
+	case nil:
+		return true
+	case []byte:
+		return v == nil
+	case json.RawMessage:
+		return v == nil
+	}
+
+	return false
+
+}
+
+// TypeOf returns the logical type of a canonical value.
+func TypeOf(value interface{}) Type {
+
+	switch v := value.(type) {
+	case nil:
+		return TypeNull
+	case int, int8, int16, int32, int64:
+		return TypeInt
+	case uint, uint8, uint16, uint32, uint64:
+		return TypeUint
+	case float32, float64:
+		return TypeFloat
+	case bool:
+		return TypeBool
+	case time.Time:
+		return TypeDatetime
+	case json.RawMessage:
+		return TypeJSON
+	case []byte:
+		if v == nil {
+			return TypeNull
+		}
+		return TypeBytes
+	}
+
+	return TypeString
+
+}
+
+func ToInt(value interface{}) (interface{}, error) {
+
+	switch v := value.(type) {
+	case int:
+		return int64(v), nil
+	case int8:
+		return int64(v), nil
+	case int16:
+		return int64(v), nil
+	case int32:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case uint:
+		return ToInt(uint64(v))
+	case uint8:
+		return int64(v), nil
+	case uint16:
+		return int64(v), nil
+	case uint32:
+		return int64(v), nil
+	case uint64:
+		if v > math.MaxInt64 {
+			return nil, fmt.Errorf("value %d overflows int", v)
+		}
+		return int64(v), nil
+	case float32:
+		return ToInt(float64(v))
+	case float64:
+		if v != math.Trunc(v) || v > math.MaxInt64 || v < math.MinInt64 {
+			return nil, fmt.Errorf("value %v is not an int", v)
+		}
+		return int64(v), nil
+	case bool:
+		if v {
+			return int64(1), nil
+		}
+		return int64(0), nil
+	case time.Time:
+		return v.Unix(), nil
+	}
+
+	s, err := toText(value)
+
+	if err != nil {
+		return nil, err
+	}
+
+	i, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+
+	if err != nil {
+		return nil, fmt.Errorf("value `%s` is not an int", s)
+	}
+
+	return i, nil
+
+}
+
+func ToUint(value interface{}) (interface{}, error) {
+
+	switch v := value.(type) {
+	case uint:
+		return uint64(v), nil
+	case uint8:
+		return uint64(v), nil
+	case uint16:
+		return uint64(v), nil
+	case uint32:
+		return uint64(v), nil
+	case uint64:
+		return v, nil
+	case int, int8, int16, int32, int64, float32, float64, bool:
+		i, err := ToInt(v)
+		if err != nil {
+			return nil, err
+		}
+		if i.(int64) < 0 {
+			return nil, fmt.Errorf("value %d is not an uint", i)
+		}
+		return uint64(i.(int64)), nil
+	}
+
+	s, err := toText(value)
+
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := strconv.ParseUint(strings.TrimSpace(s), 10, 64)
+
+	if err != nil {
+		return nil, fmt.Errorf("value `%s` is not an uint", s)
+	}
+
+	return u, nil
+
+}
+
+func ToFloat(value interface{}) (interface{}, error) {
+
+	switch v := value.(type) {
+	case float32:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	case int, int8, int16, int32, int64:
+		i, _ := ToInt(v)
+		return float64(i.(int64)), nil
+	case uint, uint8, uint16, uint32, uint64:
+		u, _ := ToUint(v)
+		return float64(u.(uint64)), nil
+	case bool:
+		if v {
+			return float64(1), nil
+		}
+		return float64(0), nil
+	}
+
+	s, err := toText(value)
+
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+
+	if err != nil {
+		return nil, fmt.Errorf("value `%s` is not a float", s)
+	}
+
+	return f, nil
+
+}
+
+// ToDecimal returns the exact textual representation of a decimal number.
+func ToDecimal(value interface{}) (interface{}, error) {
+
+	switch v := value.(type) {
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprint(v), nil
+	}
+
+	s, err := toText(value)
+
+	if err != nil {
+		return nil, err
+	}
+
+	s = strings.TrimSpace(s)
+
+	if _, ok := new(big.Float).SetString(s); !ok {
+		return nil, fmt.Errorf("value `%s` is not a decimal", s)
+	}
+
+	return s, nil
+
+}
+
+func ToString(value interface{}) (interface{}, error) {
+
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	case json.RawMessage:
+		return string(v), nil
+	case time.Time:
+		return FormatTime(v, TypeDatetime), nil
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case fmt.Stringer:
+		return v.String(), nil
+	}
+
+	return fmt.Sprint(value), nil
+
+}
+
+func ToBytes(value interface{}) (interface{}, error) {
+
+	switch v := value.(type) {
+	case []byte:
+		return v, nil
+	case json.RawMessage:
+		return []byte(v), nil
+	case string:
+		return []byte(v), nil
+	}
+
+	s, err := ToString(value)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(s.(string)), nil
+
+}
+
+func ToBool(value interface{}) (interface{}, error) {
+
+	switch v := value.(type) {
+	case bool:
+		return v, nil
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		f, _ := ToFloat(v)
+		return f.(float64) != 0, nil
+	}
+
+	s, err := toText(value)
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "1", "true", "t", "yes", "y", "on":
+		return true, nil
+	case "0", "false", "f", "no", "n", "off":
+		return false, nil
+	}
+
+	// BIT(1) columns are read as a single raw byte
+	if b, ok := value.([]byte); ok && len(b) == 1 && b[0] <= 1 {
+		return b[0] == 1, nil
+	}
+
+	return nil, fmt.Errorf("value `%s` is not a bool", s)
+
+}
+
+// ToTime parses dates and times, interpreting values without timezone in the given location.
+// MySQL zero dates are converted to NULL.
+func ToTime(value interface{}, location *time.Location) (interface{}, error) {
+
+	if location == nil {
+		location = time.UTC
+	}
+
+	switch v := value.(type) {
+	case time.Time:
+		return v, nil
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		i, err := ToInt(v)
+		if err != nil {
+			return nil, err
+		}
+		return time.Unix(i.(int64), 0).In(location), nil
+	}
+
+	s, err := toText(value)
+
+	if err != nil {
+		return nil, err
+	}
+
+	s = strings.TrimSpace(s)
+
+	if strings.HasPrefix(s, "0000-00-00") {
+		return nil, nil
+	}
+
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, s, location); err == nil {
+			return t, nil
+		}
+	}
+
+	return nil, fmt.Errorf("value `%s` is not a date or time", s)
+
+}
+
+func ToJSON(value interface{}) (interface{}, error) {
+
+	switch v := value.(type) {
+	case json.RawMessage:
+		return v, nil
+	case []byte:
+		if !json.Valid(v) {
+			return nil, fmt.Errorf("value `%s` is not valid JSON", string(v))
+		}
+		return json.RawMessage(v), nil
+	case string:
+		if !json.Valid([]byte(v)) {
+			return nil, fmt.Errorf("value `%s` is not valid JSON", v)
+		}
+		return json.RawMessage(v), nil
+	}
+
+	encoded, err := json.Marshal(value)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return json.RawMessage(encoded), nil
+
+}
+
+// Format returns the textual representation of a value for the given logical type,
+// or false when the value is NULL. An empty type is inferred from the value.
+func Format(value interface{}, t Type) (string, bool) {
+
+	if IsNull(value) {
+		return "", false
+	}
+
+	if t == "" {
+		t = TypeOf(value)
+	}
+
+	switch v := value.(type) {
+	case time.Time:
+		return FormatTime(v, t), true
+	case []byte:
+		return string(v), true
+	case bool:
+		if v {
+			return "1", true
+		}
+		return "0", true
+	}
+
+	s, _ := ToString(value)
+
+	return s.(string), true
+
+}
+
+// FormatTime formats dates as `2006-01-02` and datetimes as `2006-01-02 15:04:05`,
+// appending the timezone offset when not UTC.
+func FormatTime(t time.Time, logical Type) string {
+
+	if logical == TypeDate {
+		return t.Format(DateLayout)
+	}
+
+	if t.Location() == time.UTC {
+		return t.Format(DatetimeLayout)
+	}
+
+	return t.Format(DatetimeLayout + "Z07:00")
+
+}
+
+func toText(value interface{}) (string, error) {
+
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	case json.RawMessage:
+		return string(v), nil
+	case fmt.Stringer:
+		return v.String(), nil
+	}
+
+	return "", fmt.Errorf("unsupported value `%v` of type %T", value, value)
+
+}
@@ -0,0 +1,188 @@
+package gomulus
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestConvert(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		value    interface{}
+		t        Type
+		expected interface{}
+		fails    bool
+	}{
+		{"int from int32", int32(-7), TypeInt, int64(-7), false},
+		{"int from text", []byte(" 42 "), TypeInt, int64(42), false},
+		{"int from whole float", float64(3), TypeInt, int64(3), false},
+		{"int from fractional float", 3.5, TypeInt, nil, true},
+		{"int overflow from uint64", uint64(math.MaxUint64), TypeInt, nil, true},
+		{"int overflow from text", "9223372036854775808", TypeInt, nil, true},
+		{"int unparseable", "forty", TypeInt, nil, true},
+		{"int NULL", nil, TypeInt, nil, false},
+		{"uint from text", "18446744073709551615", TypeUint, uint64(math.MaxUint64), false},
+		{"uint negative", int64(-1), TypeUint, nil, true},
+		{"uint overflow from text", "18446744073709551616", TypeUint, nil, true},
+		{"uint NULL bytes", []byte(nil), TypeUint, nil, false},
+		{"float from int", int64(2), TypeFloat, float64(2), false},
+		{"float from text", "1.25", TypeFloat, 1.25, false},
+		{"float overflow from text", "1e400", TypeFloat, nil, true},
+		{"float unparseable", "1,25", TypeFloat, nil, true},
+		{"decimal text kept exact", []byte("12345678901234567890.000000001"), TypeDecimal, "12345678901234567890.000000001", false},
+		{"decimal text trimmed", " -0.10 ", TypeDecimal, "-0.10", false},
+		{"decimal from float", 0.1, TypeDecimal, "0.1", false},
+		{"decimal from int", int64(-3), TypeDecimal, "-3", false},
+		{"decimal unparseable", "1.2.3", TypeDecimal, nil, true},
+		{"decimal NULL", nil, TypeDecimal, nil, false},
+		{"string from bytes", []byte("abc"), TypeString, "abc", false},
+		{"string from float", 1.5, TypeString, "1.5", false},
+		{"string from time", time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), TypeString, "2020-01-02 03:04:05", false},
+		{"string untyped", int64(9), "", "9", false},
+		{"bytes from string", "abc", TypeBytes, []byte("abc"), false},
+		{"bool from text", "Yes", TypeBool, true, false},
+		{"bool from number", int64(0), TypeBool, false, false},
+		{"bool from bit", []byte{1}, TypeBool, true, false},
+		{"bool unparseable", "maybe", TypeBool, nil, true},
+		{"date from datetime text", "2020-01-02 23:59:59", TypeDate, time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC), false},
+		{"date zero", "0000-00-00", TypeDate, nil, false},
+		{"date unparseable", "02/01/2020", TypeDate, nil, true},
+		{"datetime from text", "2020-01-02T03:04:05.5", TypeDatetime, time.Date(2020, 1, 2, 3, 4, 5, 500000000, time.UTC), false},
+		{"datetime with offset", "2020-01-02 03:04:05+02:00", TypeDatetime, time.Date(2020, 1, 2, 1, 4, 5, 0, time.UTC), false},
+		{"datetime from unix", int64(0), TypeDatetime, time.Unix(0, 0).UTC(), false},
+		{"datetime zero", []byte("0000-00-00 00:00:00"), TypeDatetime, nil, false},
+		{"datetime unparseable", "yesterday", TypeDatetime, nil, true},
+		{"json from text", `{"a":1}`, TypeJSON, json.RawMessage(`{"a":1}`), false},
+		{"json from value", []interface{}{1, "a"}, TypeJSON, json.RawMessage(`[1,"a"]`), false},
+		{"json invalid", "{", TypeJSON, nil, true},
+		{"json NULL", json.RawMessage(nil), TypeJSON, nil, false},
+		{"null", "anything", TypeNull, nil, false},
+		{"unknown type", "a", Type("money"), nil, true},
+	}
+
+	for _, test := range tests {
+
+		result, err := Convert(test.value, test.t)
+
+		if test.fails {
+			if err == nil {
+				t.Errorf("%s: expected an error, got %#v", test.name, result)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", test.name, err.Error())
+			continue
+		}
+
+		if expected, ok := test.expected.(time.Time); ok {
+			if actual, ok := result.(time.Time); !ok || !actual.Equal(expected) {
+				t.Errorf("%s: expected %v, got %#v", test.name, expected, result)
+			}
+			continue
+		}
+
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("%s: expected %#v, got %#v", test.name, test.expected, result)
+		}
+
+	}
+
+}
+
+func TestConvertRow(t *testing.T) {
+
+	schema := Schema{Columns: []Column{
+		{Name: "id", Type: TypeUint},
+		{Name: "price", Type: TypeDecimal},
+		{Name: "created", Type: TypeDatetime, Timezone: "Europe/Rome"},
+		{Name: "deleted", Type: TypeDatetime},
+	}}
+
+	tests := []struct {
+		name     string
+		row      []interface{}
+		expected []interface{}
+		fails    string
+	}{
+		{
+			"every type",
+			[]interface{}{[]byte("1"), []byte("9.990"), "2020-07-01 12:00:00", nil},
+			[]interface{}{uint64(1), "9.990", time.Date(2020, 7, 1, 10, 0, 0, 0, time.UTC), nil},
+			"",
+		},
+		{
+			"extra values left as they are",
+			[]interface{}{"2", "0", nil, nil, "extra"},
+			[]interface{}{uint64(2), "0", nil, nil, "extra"},
+			"",
+		},
+		{
+			"missing values",
+			[]interface{}{"3"},
+			[]interface{}{uint64(3)},
+			"",
+		},
+		{
+			"unparseable date",
+			[]interface{}{"4", "1", "2020-13-45", nil},
+			nil,
+			"column `created`: value `2020-13-45` is not a date or time",
+		},
+		{
+			"overflow",
+			[]interface{}{"-1", "1", nil, nil},
+			nil,
+			"column `id`: value `-1` is not an uint",
+		},
+	}
+
+	for _, test := range tests {
+
+		result, err := ConvertRow(test.row, schema)
+
+		if test.fails != "" {
+			if err == nil || err.Error() != test.fails {
+				t.Errorf("%s: expected error `%s`, got %v", test.name, test.fails, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", test.name, err.Error())
+			continue
+		}
+
+		if len(result) != len(test.expected) {
+			t.Errorf("%s: expected %d values, got %d", test.name, len(test.expected), len(result))
+			continue
+		}
+
+		for i := range result {
+
+			if expected, ok := test.expected[i].(time.Time); ok {
+				if actual, ok := result[i].(time.Time); !ok || !actual.Equal(expected) {
+					t.Errorf("%s: value %d expected %v, got %#v", test.name, i, expected, result[i])
+				}
+				continue
+			}
+
+			if !reflect.DeepEqual(result[i], test.expected[i]) {
+				t.Errorf("%s: value %d expected %#v, got %#v", test.name, i, test.expected[i], result[i])
+			}
+
+		}
+
+		// the row is converted in place
+		if &result[0] != &test.row[0] {
+			t.Errorf("%s: expected the row to be converted in place", test.name)
+		}
+
+	}
+
+}
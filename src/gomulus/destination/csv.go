@@ -1,17 +1,42 @@
 package gomulus
 
 import (
+	"bytes"
 	"encoding/csv"
+	"fmt"
 	"github.com/gofrs/flock"
 	"gomulus"
+	"math"
+	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
 )
 
+func init() {
+	gomulus.RegisterDestination("csv", func() gomulus.DestinationInterface {
+		return &DefaultCSVDestination{}
+	})
+}
+
 type DefaultCSVDestination struct {
 	Config gomulus.DriverConfig
 	Flock  *flock.Flock
 	File   *os.File
+	Schema gomulus.Schema
+	Null   string
+	Mutex  sync.Mutex
+	// one destination per table when the path contains `{table}`
+	Options map[string]interface{}
+	Tables  map[string]*DefaultCSVDestination
+}
+
+func (d *DefaultCSVDestination) SetConfig(config gomulus.DriverConfig) {
+
+	d.Config = config
+
 }
 
 func (d *DefaultCSVDestination) New(config map[string]interface{}) error {
@@ -20,6 +45,14 @@ func (d *DefaultCSVDestination) New(config map[string]interface{}) error {
 	var file *os.File
 	var path, _ = config["path"].(string)
 	var truncate, _ = config["truncate"].(bool)
+	var header, _ = config["header"].(bool)
+	var null, _ = config["null"].(string)
+
+	if strings.Contains(path, "{table}") {
+		d.Options = config
+		d.Tables = make(map[string]*DefaultCSVDestination, 0)
+		return nil
+	}
 
 	if path, err = filepath.Abs(path); err != nil {
 		return err
@@ -37,50 +70,242 @@ func (d *DefaultCSVDestination) New(config map[string]interface{}) error {
 
 	d.Flock = flock.New(path)
 	d.File = file
+	d.Null = null
+
+	if header && len(d.Schema.Columns) > 0 {
+
+		info, err := file.Stat()
+
+		if err != nil {
+			return err
+		}
+
+		if info.Size() == 0 {
+
+			wr := csv.NewWriter(file)
+
+			if err = wr.Write(d.Schema.Names()); err != nil {
+				return err
+			}
+
+			wr.Flush()
+
+			if err = wr.Error(); err != nil {
+				return err
+			}
+
+		}
+
+	}
 
 	return nil
 
 }
 
+// PersistStream writes the rows in chunks as large as the channel buffer.
+func (d *DefaultCSVDestination) PersistStream(rows <-chan []interface{}) (int, error) {
+
+	var count = 0
+	var size = int(math.Max(1, float64(cap(rows))))
+	var chunk = make([][]interface{}, 0, size)
+
+	for row := range rows {
+
+		if chunk = append(chunk, row); len(chunk) < size {
+			continue
+		}
+
+		n, err := d.PersistData(chunk)
+
+		if err != nil {
+			return count + n, err
+		}
+
+		count += n
+		chunk = chunk[:0]
+
+	}
+
+	if len(chunk) > 0 {
+
+		n, err := d.PersistData(chunk)
+
+		if err != nil {
+			return count + n, err
+		}
+
+		count += n
+
+	}
+
+	return count, nil
+
+}
+
+// PersistBatch writes the rows to the file of the table they were read from, when tagged with one.
+func (d *DefaultCSVDestination) PersistBatch(batch gomulus.Batch) (int, error) {
+
+	if batch.Table == "" {
+		return d.PersistData(batch.Data)
+	}
+
+	if d.Tables == nil {
+		return 0, fmt.Errorf("unable to persist table `%s`, the path must contain `{table}` to write several tables", batch.Table)
+	}
+
+	table, err := d.table(batch)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return table.PersistData(batch.Data)
+
+}
+
+// table returns the destination writing the file of the batch table, opening it the first time.
+func (d *DefaultCSVDestination) table(batch gomulus.Batch) (*DefaultCSVDestination, error) {
+
+	d.Mutex.Lock()
+	defer d.Mutex.Unlock()
+
+	if table, ok := d.Tables[batch.Table]; ok {
+		return table, nil
+	}
+
+	options := make(map[string]interface{}, len(d.Options))
+
+	for key, value := range d.Options {
+		options[key] = value
+	}
+
+	options["path"] = strings.Replace(options["path"].(string), "{table}", batch.Table, -1)
+
+	table := &DefaultCSVDestination{Config: d.Config, Schema: batch.Schema}
+
+	if err := table.New(options); err != nil {
+		return nil, err
+	}
+
+	d.Tables[batch.Table] = table
+
+	return table, nil
+
+}
+
+func (d *DefaultCSVDestination) Close(success bool) error {
+
+	var err error
+
+	for _, table := range d.Tables {
+		if e := table.Close(success); e != nil && err == nil {
+			err = e
+		}
+	}
+
+	if d.File == nil {
+		return err
+	}
+
+	if err := d.File.Sync(); err != nil {
+		_ = d.File.Close()
+		return err
+	}
+
+	return d.File.Close()
+
+}
+
+func (d *DefaultCSVDestination) SetSchema(schema gomulus.Schema) error {
+
+	d.Schema = schema
+
+	return nil
+
+}
+
+func (d *DefaultCSVDestination) ParseURL(u *url.URL) (map[string]interface{}, error) {
+
+	options := gomulus.URLOptions(u)
+
+	if u.Opaque != "" {
+		options["path"] = u.Opaque
+	} else {
+		options["path"] = u.Host + u.Path
+	}
+
+	if options["path"] == "" {
+		return nil, fmt.Errorf("invalid csv URL, expected csv:///path/to/file.csv")
+	}
+
+	return options, nil
+
+}
+
 func (d *DefaultCSVDestination) PreProcessData(data [][]interface{}) ([][]interface{}, error) {
 
 	return data, nil
 
 }
 
+// PersistData writes the rows to the file at once, returning the rows written in full when it fails.
 func (d *DefaultCSVDestination) PersistData(data [][]interface{}) (int, error) {
 
-	locked, _ := d.Flock.TryLock()
+	d.Mutex.Lock()
+	defer d.Mutex.Unlock()
 
-	file := d.File
+	if d.File == nil {
+		return 0, fmt.Errorf("unable to persist rows without table, the path contains `{table}`")
+	}
+
+	if locked, _ := d.Flock.TryLock(); locked {
+		defer d.Flock.Unlock()
+	}
+
+	var buffer bytes.Buffer
+	// offset of the end of every row in the buffer
+	var ends = make([]int, 0, len(data))
 
-	wr := csv.NewWriter(file)
+	wr := csv.NewWriter(&buffer)
 
 	for _, row := range data {
 
 		values := make([]string, 0)
 
-		for _, column := range row {
-			col, _ := column.([]byte)
-			values = append(values, string(col))
-		}
+		for i, column := range row {
 
-		err := wr.Write(values)
+			var logical gomulus.Type
+
+			if i < len(d.Schema.Columns) {
+				logical = d.Schema.Columns[i].Type
+			}
+
+			if value, ok := gomulus.Format(column, logical); ok {
+				values = append(values, value)
+			} else {
+				values = append(values, d.Null)
+			}
 
-		if err != nil {
-			return len(data), err
 		}
 
-	}
+		if err := wr.Write(values); err != nil {
+			return 0, err
+		}
 
-	wr.Flush()
+		wr.Flush()
+
+		if err := wr.Error(); err != nil {
+			return 0, err
+		}
+
+		ends = append(ends, buffer.Len())
 
-	if locked {
-		_ = d.Flock.Unlock()
 	}
 
-	if wr.Error() != nil {
-		return len(data), wr.Error()
+	n, err := d.File.Write(buffer.Bytes())
+
+	if err != nil {
+		return sort.SearchInts(ends, n+1), err
 	}
 
 	return len(data), nil
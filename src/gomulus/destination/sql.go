@@ -0,0 +1,1135 @@
+package gomulus
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gomulus"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// placeholders allowed in a prepared statement by the MySQL protocol
+const MaxPlaceholders = 65535
+
+func init() {
+	gomulus.RegisterDestination("sql", func() gomulus.DestinationInterface {
+		return &DefaultSQLDestination{}
+	})
+}
+
+// LoaderInterface inserts the rows of a batch within its transaction, in an insert mode of the
+// driver embedding the SQL destination, e.g. load_data for the "mysql" one.
+type LoaderInterface interface {
+	Load(*sql.Tx, string, gomulus.Schema, [][]interface{}) error
+}
+
+// DefaultSQLDestination inserts the rows through any database/sql driver, in the SQL of the dialect
+// registered under the driver name or the dialect option.
+type DefaultSQLDestination struct {
+	Config   gomulus.DriverConfig
+	DB       *sql.DB
+	Dialect  gomulus.DialectInterface
+	Database string
+	Table    string
+	Schema   gomulus.Schema
+	Truncate bool
+	// location of the connection, dates and times being written in it by every insert mode when set
+	Location *time.Location
+	// row, multirow or one of the loaders
+	InsertMode string
+	Loaders    map[string]LoaderInterface
+	// bytes of a multirow statement, unlimited when 0
+	MaxPacket int
+	// error, ignore, replace or update
+	OnConflict    string
+	UpdateColumns []string
+	// destination columns of the source values, in order
+	Columns []string
+	// tables created from the source schema, unless they exist
+	CreateTable bool
+	TypeMap     map[string]interface{}
+	PrimaryKey  []string
+	// appended to the CREATE TABLE statements, e.g. the MySQL engine
+	TableOptions string
+	// rows are loaded into a staging copy of every table, swapped with it on success
+	Swap    bool
+	KeepOld bool
+	// batches recorded in the gomulus_ledger table under the run ID, and skipped once recorded
+	Ledger bool
+	RunID  string
+	// statements run at the end of the run
+	PostSQL []string
+	// fail, or reject: the rows of a failing batch are then inserted one by one and the failing ones
+	// written with their error to the reject file
+	OnError string
+	Rejects *os.File
+	// tables of the batches already prepared, truncated when required
+	Tables map[string]bool
+	Mutex  sync.Mutex
+	// quoted column list and columns updated on conflict of every prepared table
+	Mappings map[string]string
+	Updates  map[string][]string
+	// rows written to every table
+	Counts map[string]int64
+}
+
+func (d *DefaultSQLDestination) SetConfig(config gomulus.DriverConfig) {
+
+	d.Config = config
+
+}
+
+func (d *DefaultSQLDestination) New(config map[string]interface{}) error {
+
+	var driver, _ = config["driver"].(string)
+	var dialectName, _ = config["dialect"].(string)
+
+	if driver == "" {
+		return errors.New("no database/sql driver given, set the driver option")
+	}
+
+	if dialectName == "" {
+		dialectName = driver
+	}
+
+	dialect, ok := gomulus.GetDialect(dialectName)
+
+	if !ok {
+		return fmt.Errorf("no dialect found under the name `%s`", dialectName)
+	}
+
+	return d.open(driver, dialect, config)
+
+}
+
+// open connects to the database through the driver and prepares the writes by the options
+// shared by the SQL destinations.
+func (d *DefaultSQLDestination) open(driver string, dialect gomulus.DialectInterface, config map[string]interface{}) (err error) {
+
+	var db *sql.DB
+	var truncate, _ = config["truncate"].(bool)
+	var database, _ = config["database"].(string)
+	var endpoint, _ = config["host"].(string)
+	var table, _ = config["table"].(string)
+	var insertMode, _ = config["insert_mode"].(string)
+	var onConflict, _ = config["on_conflict"].(string)
+	var updateColumns = gomulus.StringList(config["update_columns"])
+	var columns = gomulus.StringList(config["columns"])
+	var createTable, _ = config["create_table"].(string)
+	var typeMap, _ = config["type_map"].(map[string]interface{})
+	var primaryKey = gomulus.StringList(config["primary_key"])
+	var loadStrategy, _ = config["load_strategy"].(string)
+	var keepOld, _ = config["keep_old"].(bool)
+	var ledger, _ = config["ledger"].(bool)
+	var runID, _ = config["run_id"].(string)
+	var postSQL = gomulus.StringList(config["post_sql"])
+	var onError, _ = config["on_error"].(string)
+	var rejectPath, _ = config["reject_path"].(string)
+	var tables []string
+
+	if ok, _ := regexp.MatchString(`^[\p{L}_][\p{L}\p{N}@$#_]{0,127}$`, database); !ok {
+		return errors.New(fmt.Sprintf("invalid database name `%s`", database))
+	}
+
+	// without a table, rows are written to the tables they were read from
+	if ok, _ := regexp.MatchString(`^[\p{L}_][\p{L}\p{N}@$#_]{0,127}$`, table); !ok && table != "" {
+		return errors.New(fmt.Sprintf("invalid table name `%s`", table))
+	}
+
+	switch {
+	case insertMode == "":
+		insertMode = "row"
+	case insertMode == "row" || insertMode == "multirow" || d.Loaders[insertMode] != nil:
+	default:
+		return fmt.Errorf("invalid insert_mode `%s`, expected %s", insertMode, d.insertModes())
+	}
+
+	switch onConflict {
+	case "":
+		onConflict = "error"
+	case "error":
+	case "ignore", "replace", "update":
+		if _, ok := dialect.(gomulus.ConflictDialectInterface); !ok {
+			return fmt.Errorf("the `%s` driver doesn't support on_conflict %s", driver, onConflict)
+		}
+	default:
+		return fmt.Errorf("invalid on_conflict `%s`, expected error, ignore, replace or update", onConflict)
+	}
+
+	if createTable != "" && createTable != "if_not_exists" {
+		return fmt.Errorf("invalid create_table `%s`, expected if_not_exists", createTable)
+	}
+
+	if _, ok := dialect.(gomulus.ColumnTypeDialectInterface); createTable != "" && !ok {
+		return fmt.Errorf("the `%s` driver doesn't support create_table", driver)
+	}
+
+	if loadStrategy != "" && loadStrategy != "direct" && loadStrategy != "swap" {
+		return fmt.Errorf("invalid load_strategy `%s`, expected direct or swap", loadStrategy)
+	}
+
+	if _, ok := dialect.(gomulus.SwapDialectInterface); loadStrategy == "swap" && !ok {
+		return fmt.Errorf("the `%s` driver doesn't support load_strategy swap", driver)
+	}
+
+	switch onError {
+	case "":
+		onError = "fail"
+	case "fail":
+	case "reject":
+		if rejectPath == "" {
+			return errors.New("on_error reject requires a reject_path")
+		}
+	default:
+		return fmt.Errorf("invalid on_error `%s`, expected fail or reject", onError)
+	}
+
+	if ledger && runID == "" {
+		return errors.New("the ledger requires a run_id")
+	}
+
+	// both would empty the rows of the batches the ledger skips
+	if ledger && (truncate || loadStrategy == "swap") {
+		return errors.New("the ledger can't be combined with truncate or load_strategy swap")
+	}
+
+	// batches are recorded unless already there
+	if _, ok := dialect.(gomulus.ConflictDialectInterface); ledger && !ok {
+		return fmt.Errorf("the `%s` driver doesn't support the ledger", driver)
+	}
+
+	for _, column := range append(append(columns, updateColumns...), primaryKey...) {
+		if ok, _ := regexp.MatchString(`^[\p{L}_][\p{L}\p{N}@$#_]{0,127}$`, column); !ok {
+			return errors.New(fmt.Sprintf("invalid column name `%s`", column))
+		}
+	}
+
+	// one connection per worker
+	if db, err = gomulus.Connect(driver, dialect, endpoint, config, int(math.Max(1, float64(d.Config.Pool)))); err != nil {
+		return err
+	}
+
+	d.DB = db
+	d.Dialect = dialect
+
+	// the connections and the reject file are released when a later step fails
+	defer func() {
+		if err != nil {
+			d.release()
+		}
+	}()
+
+	if onError == "reject" {
+		if d.Rejects, err = os.OpenFile(rejectPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0666); err != nil {
+			return err
+		}
+	}
+
+	if ledger {
+
+		q := dialect.QuoteIdentifier
+
+		if _, err = db.Exec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s ("+
+			"%s CHAR(64) NOT NULL PRIMARY KEY, %s VARCHAR(255) NOT NULL, %s VARCHAR(64) NOT NULL, "+
+			"%s INT NOT NULL, %s TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP)", gomulus.QualifiedName(dialect, database, "gomulus_ledger"),
+			q("id"), q("run_id"), q("table_name"), q("row_count"), q("created_at"))); err != nil {
+			return fmt.Errorf("unable to create the ledger: %s", err.Error())
+		}
+
+	}
+
+	if tables, err = gomulus.ListTables(db, dialect, database); err != nil {
+		return err
+	}
+
+	if !gomulus.InSliceString(table, tables) && table != "" && createTable == "" {
+		return fmt.Errorf("table not found `%s`.`%s`", database, table)
+	}
+
+	d.Database = database
+	d.Table = table
+	d.Truncate = truncate
+	d.InsertMode = insertMode
+	d.OnConflict = onConflict
+	d.UpdateColumns = updateColumns
+	d.Columns = columns
+	d.CreateTable = createTable != ""
+	d.TypeMap = typeMap
+	d.PrimaryKey = primaryKey
+	d.Swap = loadStrategy == "swap"
+	d.KeepOld = keepOld
+	d.Counts = make(map[string]int64, 0)
+	d.Ledger = ledger
+	d.RunID = runID
+	d.PostSQL = postSQL
+	d.OnError = onError
+	d.Tables = make(map[string]bool, 0)
+	d.Mappings = make(map[string]string, 0)
+	d.Updates = make(map[string][]string, 0)
+
+	if table != "" {
+		if err = d.prepareTable(table, d.Schema); err != nil {
+			return err
+		}
+	}
+
+	return nil
+
+}
+
+// insertModes lists the insert modes, for the error of an invalid one.
+func (d *DefaultSQLDestination) insertModes() string {
+
+	modes := []string{"row", "multirow"}
+	loaders := make([]string, 0, len(d.Loaders))
+
+	for mode := range d.Loaders {
+		loaders = append(loaders, mode)
+	}
+
+	sort.Strings(loaders)
+	modes = append(modes, loaders...)
+
+	return strings.Join(modes[:len(modes)-1], ", ") + " or " + modes[len(modes)-1]
+
+}
+
+// release closes the connections and the reject file of a destination failing to open.
+func (d *DefaultSQLDestination) release() {
+
+	_ = d.DB.Close()
+
+	if d.Rejects != nil {
+		_ = d.Rejects.Close()
+	}
+
+}
+
+// prepareTable truncates the table the first time it is written, when required, and maps the source columns to its own.
+func (d *DefaultSQLDestination) prepareTable(table string, schema gomulus.Schema) error {
+
+	d.Mutex.Lock()
+	defer d.Mutex.Unlock()
+
+	if d.Tables[table] {
+		return nil
+	}
+
+	if d.CreateTable {
+		if err := d.createTable(table, schema); err != nil {
+			return err
+		}
+	}
+
+	if d.Swap {
+
+		staging := gomulus.QualifiedName(d.Dialect, d.Database, table+"__gomulus_tmp")
+
+		if _, err := d.DB.Exec("DROP TABLE IF EXISTS " + staging); err != nil {
+			return err
+		}
+
+		query := d.Dialect.(gomulus.SwapDialectInterface).CreateLike(staging, gomulus.QualifiedName(d.Dialect, d.Database, table))
+
+		if _, err := d.DB.Exec(query); err != nil {
+			return fmt.Errorf("unable to create the staging table of `%s`.`%s`: %s", d.Database, table, err.Error())
+		}
+
+	} else if d.Truncate {
+		if _, err := d.DB.Exec(d.Dialect.Truncate(gomulus.QualifiedName(d.Dialect, d.Database, table))); err != nil {
+			return err
+		}
+	}
+
+	columns, err := d.tableColumns(table)
+
+	if err != nil {
+		return err
+	}
+
+	if len(columns) == 0 {
+		return fmt.Errorf("table not found `%s`.`%s`", d.Database, table)
+	}
+
+	mapped, err := d.mapColumns(table, schema, columns)
+
+	if err != nil {
+		return err
+	}
+
+	if len(mapped) > 0 {
+		d.Mappings[table] = d.columnList(mapped)
+	}
+
+	if d.OnConflict == "update" {
+
+		updates := d.UpdateColumns
+
+		// all the inserted columns but the primary key by default
+		if len(updates) == 0 {
+
+			keys, err := gomulus.PrimaryKey(d.DB, d.Dialect, d.Database, table)
+
+			if err != nil {
+				return err
+			}
+
+			if len(mapped) > 0 {
+				columns = mapped
+			}
+
+			for _, column := range columns {
+				if !gomulus.InSliceString(column, keys) {
+					updates = append(updates, column)
+				}
+			}
+
+		}
+
+		if len(updates) == 0 {
+			return fmt.Errorf("no columns to update on conflict in `%s`.`%s`, set the update_columns option", d.Database, table)
+		}
+
+		d.Updates[table] = updates
+
+	}
+
+	d.Tables[table] = true
+
+	return nil
+
+}
+
+// createTable creates the table with the columns of the schema, unless it exists.
+func (d *DefaultSQLDestination) createTable(table string, schema gomulus.Schema) error {
+
+	if len(schema.Columns) == 0 {
+		return fmt.Errorf("unable to create `%s`.`%s` without a source schema", d.Database, table)
+	}
+
+	definitions := make([]string, 0, len(schema.Columns)+1)
+
+	for _, column := range schema.Columns {
+		definitions = append(definitions, d.Dialect.QuoteIdentifier(column.Name)+" "+d.columnDefinition(column))
+	}
+
+	if len(d.PrimaryKey) > 0 {
+		definitions = append(definitions, "PRIMARY KEY"+d.columnList(d.PrimaryKey))
+	}
+
+	query := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s)%s",
+		gomulus.QualifiedName(d.Dialect, d.Database, table), strings.Join(definitions, ", "), d.TableOptions)
+
+	if _, err := d.DB.Exec(query); err != nil {
+		return fmt.Errorf("unable to create `%s`.`%s`: %s", d.Database, table, err.Error())
+	}
+
+	return nil
+
+}
+
+// columnDefinition returns the type of the column: the one of its logical type in the type map,
+// else the one declared in the source, when read from the same database, else the one of the dialect.
+func (d *DefaultSQLDestination) columnDefinition(column gomulus.Column) string {
+
+	var definition string
+
+	if mapped, ok := d.TypeMap[string(column.Type)].(string); ok {
+		definition = mapped
+	} else if column.Definition != "" {
+		definition = column.Definition
+	} else {
+		definition = d.Dialect.(gomulus.ColumnTypeDialectInterface).ColumnType(column)
+	}
+
+	if column.Nullable {
+		return definition + " NULL"
+	}
+
+	return definition + " NOT NULL"
+
+}
+
+// tableColumns returns the columns of the table in order, read from the information_schema.
+func (d *DefaultSQLDestination) tableColumns(table string) ([]string, error) {
+
+	query := fmt.Sprintf("SELECT COLUMN_NAME FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = %s AND TABLE_NAME = %s ORDER BY ORDINAL_POSITION",
+		d.Dialect.Placeholder(1), d.Dialect.Placeholder(2))
+
+	rows, err := gomulus.Select(d.DB, query, d.Database, table)
+
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make([]string, 0, len(rows))
+
+	for _, row := range rows {
+		columns = append(columns, fmt.Sprintf("%s", row[0]))
+	}
+
+	return columns, nil
+
+}
+
+// mapColumns returns the destination columns of the source values, by the columns option or else
+// by the names of the source schema, and none when the values are inserted by position.
+func (d *DefaultSQLDestination) mapColumns(table string, schema gomulus.Schema, columns []string) ([]string, error) {
+
+	var names = d.Columns
+	var mapped = make([]string, 0)
+	var missing = make([]string, 0)
+
+	if len(names) == 0 {
+		names = schema.Names()
+	}
+
+	for _, name := range names {
+
+		found := false
+
+		// column names are case insensitive
+		for _, column := range columns {
+			if strings.EqualFold(name, column) {
+				mapped = append(mapped, column)
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			missing = append(missing, name)
+		}
+
+	}
+
+	switch {
+	case len(d.Columns) > 0 && len(missing) > 0:
+		return nil, fmt.Errorf("columns `%s` not found in `%s`.`%s`", strings.Join(missing, "`, `"), d.Database, table)
+	case len(d.Columns) > 0 && len(schema.Columns) > 0 && len(schema.Columns) != len(d.Columns):
+		return nil, fmt.Errorf("%d columns mapped to `%s`.`%s`, the source has %d", len(d.Columns), d.Database, table, len(schema.Columns))
+	case len(d.Columns) > 0:
+		return mapped, nil
+	case len(names) > 0 && len(missing) == 0:
+		return mapped, nil
+	case len(mapped) > 0:
+		return nil, fmt.Errorf("source columns `%s` not found in `%s`.`%s`, set the columns option to map them", strings.Join(missing, "`, `"), d.Database, table)
+	case len(schema.Columns) > 0 && len(schema.Columns) != len(columns):
+		return nil, fmt.Errorf("the source has %d columns and `%s`.`%s` %d, set the columns option to map them", len(schema.Columns), d.Database, table, len(columns))
+	}
+
+	return nil, nil
+
+}
+
+// columnList returns the quoted columns in parentheses, preceded by a space.
+func (d *DefaultSQLDestination) columnList(columns []string) string {
+
+	quoted := make([]string, 0, len(columns))
+
+	for _, column := range columns {
+		quoted = append(quoted, d.Dialect.QuoteIdentifier(column))
+	}
+
+	return " (" + strings.Join(quoted, ", ") + ")"
+
+}
+
+// PersistBatch inserts the rows in the table they were read from, when tagged with one.
+func (d *DefaultSQLDestination) PersistBatch(batch gomulus.Batch) (int, error) {
+
+	var id string
+
+	if batch.Table == "" && d.Table == "" {
+		return 0, errors.New("no table to persist data to, set the table option")
+	}
+
+	if d.Ledger {
+
+		hash := sha256.New()
+
+		if err := json.NewEncoder(hash).Encode([]interface{}{d.RunID, batch.Table, batch.Job, batch.Chunk}); err != nil {
+			return 0, fmt.Errorf("unable to identify the batch: %s", err.Error())
+		}
+
+		id = hex.EncodeToString(hash.Sum(nil))
+
+	}
+
+	if batch.Table == "" {
+		return d.persist(d.Table, d.Schema, batch.Data, id)
+	}
+
+	if err := d.prepareTable(batch.Table, batch.Schema); err != nil {
+		return 0, err
+	}
+
+	return d.persist(batch.Table, batch.Schema, batch.Data, id)
+
+}
+
+// BatchOnly disables streaming with the ledger, which identifies the rows by their batch,
+// with the insert modes other than row and with rejects, which only apply to batches.
+func (d *DefaultSQLDestination) BatchOnly() bool {
+
+	return d.Ledger || d.InsertMode != "row" || d.OnError == "reject"
+
+}
+
+// RequiresDeterministicJobs is true with the ledger, which identifies the batches by their job.
+func (d *DefaultSQLDestination) RequiresDeterministicJobs() bool {
+
+	return d.Ledger
+
+}
+
+// begin starts the transaction of a batch, recording it in the ledger first when identified;
+// skip is true when the batch was already recorded.
+func (d *DefaultSQLDestination) begin(id string, table string, rows int) (tx *sql.Tx, skip bool, err error) {
+
+	if tx, err = d.DB.Begin(); err != nil || id == "" {
+		return tx, false, err
+	}
+
+	query := d.Dialect.(gomulus.ConflictDialectInterface).InsertConflict("ignore",
+		gomulus.QualifiedName(d.Dialect, d.Database, "gomulus_ledger"), d.columnList([]string{"id", "run_id", "table_name", "row_count"}), d.marks(1, 4), nil)
+
+	result, err := tx.Exec(query, id, d.RunID, table, rows)
+
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, false, err
+	}
+
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		_ = tx.Rollback()
+		return nil, err == nil, err
+	}
+
+	return tx, false, nil
+
+}
+
+// PersistStream inserts the rows as they arrive, in a single transaction.
+func (d *DefaultSQLDestination) PersistStream(rows <-chan []interface{}) (int, error) {
+
+	var err error
+	var count = 0
+	var tx *sql.Tx
+	var stmt *sql.Stmt
+
+	if d.Table == "" {
+		return 0, errors.New("no table to persist data to, set the table option")
+	}
+
+	for row := range rows {
+
+		if tx == nil {
+
+			if tx, err = d.DB.Begin(); err != nil {
+				return 0, err
+			}
+
+			if stmt, err = tx.Prepare(d.insertQuery(d.Table, len(row))); err != nil {
+				_ = tx.Rollback()
+				return 0, err
+			}
+
+			defer stmt.Close()
+
+		}
+
+		if row, err = SQLRow(row, d.Schema, d.Location); err == nil {
+			_, err = stmt.Exec(row...)
+		}
+
+		if err != nil {
+			_ = tx.Rollback()
+			return 0, err
+		}
+
+		count++
+
+	}
+
+	if tx == nil {
+		return 0, nil
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	d.count(d.Table, count)
+
+	return count, nil
+
+}
+
+func (d *DefaultSQLDestination) Close(success bool) error {
+
+	defer d.DB.Close()
+
+	var err error
+
+	if d.Swap {
+		err = d.swapTables(success)
+	}
+
+	if e := gomulus.ExecStatements(d.DB, "post_sql", d.PostSQL); e != nil && err == nil {
+		err = e
+	}
+
+	if d.Rejects != nil {
+		if e := d.Rejects.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+
+	return err
+
+}
+
+// swapTables replaces the tables with their staging tables after a successful run, and drops these otherwise.
+func (d *DefaultSQLDestination) swapTables(success bool) error {
+
+	var err error
+
+	for table := range d.Tables {
+
+		if !success {
+			_, _ = d.DB.Exec("DROP TABLE IF EXISTS " + gomulus.QualifiedName(d.Dialect, d.Database, d.loadTable(table)))
+			continue
+		}
+
+		if e := d.swapTable(table); e != nil && err == nil {
+			err = e
+		}
+
+	}
+
+	return err
+
+}
+
+// swapTable verifies the rows of the staging table and atomically replaces the table with it,
+// dropping the old table unless kept as `<table>__gomulus_old`.
+func (d *DefaultSQLDestination) swapTable(table string) error {
+
+	var count int64
+	var name = gomulus.QualifiedName(d.Dialect, d.Database, table)
+	var staging = gomulus.QualifiedName(d.Dialect, d.Database, table+"__gomulus_tmp")
+	var old = gomulus.QualifiedName(d.Dialect, d.Database, table+"__gomulus_old")
+
+	if err := d.DB.QueryRow("SELECT COUNT(*) FROM " + staging).Scan(&count); err != nil {
+		return err
+	}
+
+	written := d.Counts[table]
+
+	// conflicting rows are skipped or merged, unless failing the batch, but never add up
+	if (d.OnConflict == "error" && count != written) || count > written || (count == 0 && written > 0) {
+		return fmt.Errorf("staging table `%s`.`%s__gomulus_tmp` has %d rows, %d written, not swapped", d.Database, table, count, written)
+	}
+
+	if _, err := d.DB.Exec("DROP TABLE IF EXISTS " + old); err != nil {
+		return err
+	}
+
+	if _, err := d.DB.Exec(d.Dialect.(gomulus.SwapDialectInterface).Swap(name, staging, old)); err != nil {
+		return fmt.Errorf("unable to swap `%s`.`%s`: %s", d.Database, table, err.Error())
+	}
+
+	if d.KeepOld {
+		return nil
+	}
+
+	_, err := d.DB.Exec("DROP TABLE " + old)
+
+	return err
+
+}
+
+// loadTable returns the table the rows are inserted in, the staging one when swapping.
+func (d *DefaultSQLDestination) loadTable(table string) string {
+
+	if d.Swap {
+		return table + "__gomulus_tmp"
+	}
+
+	return table
+
+}
+
+func (d *DefaultSQLDestination) count(table string, rows int) {
+
+	d.Mutex.Lock()
+	d.Counts[table] += int64(rows)
+	d.Mutex.Unlock()
+
+}
+
+// marks returns the placeholders of a row of values, numbered from the given position.
+func (d *DefaultSQLDestination) marks(from int, columns int) string {
+
+	marks := make([]string, 0, columns)
+
+	for i := from; i < from+columns; i++ {
+		marks = append(marks, d.Dialect.Placeholder(i))
+	}
+
+	return "(" + strings.Join(marks, ",") + ")"
+
+}
+
+func (d *DefaultSQLDestination) insertQuery(table string, columns int) string {
+
+	return d.insertStatement(table, d.marks(1, columns))
+
+}
+
+// insertStatement returns the statement inserting the values in the mapped columns of the table,
+// in the dialect by the on_conflict option.
+func (d *DefaultSQLDestination) insertStatement(table string, values string) string {
+
+	d.Mutex.Lock()
+	mapping := d.Mappings[table]
+	updates := d.Updates[table]
+	d.Mutex.Unlock()
+
+	name := gomulus.QualifiedName(d.Dialect, d.Database, d.loadTable(table))
+
+	if d.OnConflict == "error" {
+		return fmt.Sprintf("INSERT INTO %s%s VALUES %s", name, mapping, values)
+	}
+
+	return d.Dialect.(gomulus.ConflictDialectInterface).InsertConflict(d.OnConflict, name, mapping, values, updates)
+
+}
+
+func (d *DefaultSQLDestination) SetSchema(schema gomulus.Schema) error {
+
+	d.Schema = schema
+
+	return nil
+
+}
+
+func (d *DefaultSQLDestination) PreProcessData(data [][]interface{}) ([][]interface{}, error) {
+
+	return data, nil
+
+}
+
+func (d *DefaultSQLDestination) PersistData(data [][]interface{}) (int, error) {
+
+	if d.Table == "" {
+		return 0, errors.New("no table to persist data to, set the table option")
+	}
+
+	return d.persist(d.Table, d.Schema, data, "")
+
+}
+
+// persist inserts the rows in a transaction, recorded in the ledger under the batch ID when given.
+func (d *DefaultSQLDestination) persist(table string, schema gomulus.Schema, data [][]interface{}, id string) (int, error) {
+
+	var n int
+	var err error
+
+	switch d.InsertMode {
+	case "multirow":
+		n, err = d.persistMultirow(table, schema, data, id)
+	case "row":
+		n, err = d.persistRows(table, schema, data, id)
+	default:
+		n, err = d.persistLoader(table, schema, data, id)
+	}
+
+	if err != nil && d.OnError == "reject" {
+		n, err = d.persistIsolated(table, schema, data, id)
+	}
+
+	if err == nil {
+		d.count(table, n)
+	}
+
+	return n, err
+
+}
+
+// persistRows inserts the rows one by one.
+func (d *DefaultSQLDestination) persistRows(table string, schema gomulus.Schema, data [][]interface{}, id string) (int, error) {
+
+	columns := 0
+	for _, row := range data {
+		columns = len(row)
+		break
+	}
+
+	query := d.insertQuery(table, columns)
+
+	tx, skip, err := d.begin(id, table, len(data))
+
+	if err != nil || skip {
+		return 0, err
+	}
+
+	err = gomulus.ExecRows(tx, query, data, func(row []interface{}) ([]interface{}, error) {
+		return SQLRow(row, schema, d.Location)
+	})
+
+	if err != nil {
+		_ = tx.Rollback()
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return len(data), nil
+
+}
+
+// persistIsolated inserts the rows one by one within savepoints of a single transaction,
+// rejecting the failing ones instead of the whole batch.
+func (d *DefaultSQLDestination) persistIsolated(table string, schema gomulus.Schema, data [][]interface{}, id string) (int, error) {
+
+	var rejected = make([][]interface{}, 0)
+	var reasons = make([]error, 0)
+
+	tx, skip, err := d.begin(id, table, len(data))
+
+	if err != nil || skip {
+		return 0, err
+	}
+
+	for _, original := range data {
+
+		row, err := SQLRow(original, schema, d.Location)
+
+		if err == nil {
+
+			if _, err := tx.Exec("SAVEPOINT gomulus_row"); err != nil {
+				_ = tx.Rollback()
+				return 0, err
+			}
+
+			if _, err = tx.Exec(d.insertQuery(table, len(row)), row...); err != nil {
+
+				// fails when the error rolled back the whole transaction, e.g. a deadlock
+				if _, e := tx.Exec("ROLLBACK TO SAVEPOINT gomulus_row"); e != nil {
+					_ = tx.Rollback()
+					return 0, err
+				}
+
+			}
+
+		}
+
+		if err != nil {
+			rejected = append(rejected, original)
+			reasons = append(reasons, err)
+		}
+
+	}
+
+	// rejected rows are written before committing, so that none is lost
+	if err = d.reject(table, rejected, reasons); err != nil {
+		_ = tx.Rollback()
+		return 0, fmt.Errorf("unable to write the rejected rows: %s", err.Error())
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return len(data) - len(rejected), nil
+
+}
+
+// reject appends the rows to the reject file, preceded by their table and error.
+func (d *DefaultSQLDestination) reject(table string, rows [][]interface{}, reasons []error) error {
+
+	if len(rows) == 0 {
+		return nil
+	}
+
+	d.Mutex.Lock()
+	defer d.Mutex.Unlock()
+
+	wr := csv.NewWriter(d.Rejects)
+
+	for i, row := range rows {
+
+		values := []string{table, reasons[i].Error()}
+
+		for _, value := range row {
+			text, _ := gomulus.Format(value, "")
+			values = append(values, text)
+		}
+
+		if err := wr.Write(values); err != nil {
+			return err
+		}
+
+	}
+
+	wr.Flush()
+
+	return wr.Error()
+
+}
+
+// persistMultirow inserts the rows in statements of several rows, each within the max packet.
+func (d *DefaultSQLDestination) persistMultirow(table string, schema gomulus.Schema, data [][]interface{}, id string) (int, error) {
+
+	var rows, size, columns = 0, 0, 0
+	var args = make([]interface{}, 0)
+	// headroom for the query text and the packet headers
+	var limit = d.MaxPacket - 1024
+
+	tx, skip, err := d.begin(id, table, len(data))
+
+	if err != nil || skip {
+		return 0, err
+	}
+
+	flush := func() error {
+
+		if rows == 0 {
+			return nil
+		}
+
+		values := make([]string, 0, rows)
+
+		for i := 0; i < rows; i++ {
+			values = append(values, d.marks(i*columns+1, columns))
+		}
+
+		_, err := tx.Exec(d.insertStatement(table, strings.Join(values, ",")), args...)
+
+		rows, size, args = 0, 0, args[:0]
+
+		return err
+
+	}
+
+	for _, row := range data {
+
+		if row, err = SQLRow(row, schema, d.Location); err != nil {
+			_ = tx.Rollback()
+			return 0, err
+		}
+
+		n := RowSize(row)
+
+		if rows > 0 && ((d.MaxPacket > 0 && size+n > limit) || len(args)+len(row) > MaxPlaceholders) {
+			if err = flush(); err != nil {
+				_ = tx.Rollback()
+				return 0, err
+			}
+		}
+
+		columns = len(row)
+		args = append(args, row...)
+		rows++
+		size += n
+
+	}
+
+	if err = flush(); err != nil {
+		_ = tx.Rollback()
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return len(data), nil
+
+}
+
+// persistLoader inserts the rows by the loader of the insert mode.
+func (d *DefaultSQLDestination) persistLoader(table string, schema gomulus.Schema, data [][]interface{}, id string) (int, error) {
+
+	tx, skip, err := d.begin(id, table, len(data))
+
+	if err != nil || skip {
+		return 0, err
+	}
+
+	if err = d.Loaders[d.InsertMode].Load(tx, table, schema, data); err != nil {
+		_ = tx.Rollback()
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return len(data), nil
+
+}
+
+// SQLRow converts the row following the schema and, given the location of the connection, formats its
+// dates and times, datetimes in that location, so that every insert mode writes the same values.
+func SQLRow(row []interface{}, schema gomulus.Schema, location *time.Location) ([]interface{}, error) {
+
+	row, err := gomulus.ConvertRow(row, schema)
+
+	if err != nil || location == nil {
+		return row, err
+	}
+
+	for i, value := range row {
+
+		t, ok := value.(time.Time)
+
+		if !ok {
+			continue
+		}
+
+		if i < len(schema.Columns) && schema.Columns[i].Type == gomulus.TypeDate {
+			row[i] = t.Format(gomulus.DateLayout)
+		} else {
+			row[i] = t.In(location).Format(gomulus.DatetimeLayout)
+		}
+
+	}
+
+	return row, nil
+
+}
+
+// RowSize estimates the bytes a row takes in the packet of a prepared statement.
+func RowSize(row []interface{}) int {
+
+	size := 0
+
+	for _, value := range row {
+		switch v := value.(type) {
+		case string:
+			size += len(v) + 9
+		case []byte:
+			size += len(v) + 9
+		default:
+			size += 16
+		}
+	}
+
+	return size
+
+}
@@ -0,0 +1,230 @@
+package gomulus
+
+import (
+	"database/sql/driver"
+	"gomulus"
+	dialect "gomulus/dialect"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeDestination returns a destination writing `shop`.`orders` (id, name, note) to a fake database.
+func fakeDestination(t *testing.T, onConflict string, updateColumns []string) (*DefaultSQLDestination, *fakeDatabase) {
+
+	database := &fakeDatabase{columns: [][]driver.Value{{"id", "PRI"}, {"name", ""}, {"note", ""}}}
+
+	d := &DefaultSQLDestination{
+		DB:            openFake(t, database),
+		Dialect:       dialect.MysqlDialect{},
+		Database:      "shop",
+		Table:         "orders",
+		InsertMode:    "row",
+		OnConflict:    onConflict,
+		UpdateColumns: updateColumns,
+		Location:      time.UTC,
+		Tables:        make(map[string]bool, 0),
+		Mappings:      make(map[string]string, 0),
+		Updates:       make(map[string][]string, 0),
+		Counts:        make(map[string]int64, 0),
+	}
+
+	d.Schema = gomulus.Schema{Columns: []gomulus.Column{{Name: "id", Type: gomulus.TypeInt}, {Name: "name"}, {Name: "note"}}}
+
+	if err := d.prepareTable("orders", d.Schema); err != nil {
+		t.Fatal(err)
+	}
+
+	return d, database
+
+}
+
+func TestOnConflict(t *testing.T) {
+
+	tests := []struct {
+		name          string
+		onConflict    string
+		updateColumns []string
+		expected      string
+	}{
+		{"error", "error", nil, "INSERT INTO `shop`.`orders` (`id`, `name`, `note`) VALUES (?,?,?)"},
+		{"ignore", "ignore", nil, "INSERT IGNORE INTO `shop`.`orders` (`id`, `name`, `note`) VALUES (?,?,?)"},
+		{"replace", "replace", nil, "REPLACE INTO `shop`.`orders` (`id`, `name`, `note`) VALUES (?,?,?)"},
+		{"update every column but the key", "update", nil, "INSERT INTO `shop`.`orders` (`id`, `name`, `note`) VALUES (?,?,?) ON DUPLICATE KEY UPDATE `name` = VALUES(`name`), `note` = VALUES(`note`)"},
+		{"update the given columns", "update", []string{"note"}, "INSERT INTO `shop`.`orders` (`id`, `name`, `note`) VALUES (?,?,?) ON DUPLICATE KEY UPDATE `note` = VALUES(`note`)"},
+	}
+
+	for _, test := range tests {
+
+		t.Run(strings.Replace(test.name, " ", "_", -1), func(t *testing.T) {
+
+			d, database := fakeDestination(t, test.onConflict, test.updateColumns)
+
+			if _, err := d.PersistData([][]interface{}{{int64(1), "first", nil}}); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(database.execs) != 1 || database.execs[0].query != test.expected {
+				t.Errorf("expected `%s`, got %v", test.expected, database.execs)
+			}
+
+		})
+
+	}
+
+}
+
+func TestPersistMultirow(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		rows      int
+		maxPacket int
+		expected  []int
+	}{
+		{"single statement", 5, 1 << 20, []int{5}},
+		// rows of 48 bytes, within the 1024 bytes of headroom
+		{"split by max_allowed_packet", 5, 1024 + 100, []int{2, 2, 1}},
+		{"split by placeholders", 30000, 1 << 30, []int{MaxPlaceholders / 3, 30000 - MaxPlaceholders/3}},
+	}
+
+	for _, test := range tests {
+
+		t.Run(strings.Replace(test.name, " ", "_", -1), func(t *testing.T) {
+
+			d, database := fakeDestination(t, "error", nil)
+			d.InsertMode = "multirow"
+			d.MaxPacket = test.maxPacket
+
+			data := make([][]interface{}, test.rows)
+
+			for i := range data {
+				data[i] = []interface{}{int64(i), int64(i), int64(i)}
+			}
+
+			n, err := d.PersistData(data)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if n != test.rows {
+				t.Errorf("expected %d rows persisted, got %d", test.rows, n)
+			}
+
+			statements := make([]int, 0)
+			next := int64(0)
+
+			for _, exec := range database.execs {
+
+				rows := len(exec.args) / 3
+
+				if marks := strings.Count(exec.query, "(?,?,?)"); marks != rows {
+					t.Errorf("%d rows of values for %d arguments", marks, len(exec.args))
+				}
+
+				// rows are kept in order across the statements
+				if exec.args[0] != next {
+					t.Errorf("expected the statement to start at row %d, got %v", next, exec.args[0])
+				}
+
+				next += int64(rows)
+				statements = append(statements, rows)
+
+			}
+
+			if !reflect.DeepEqual(statements, test.expected) {
+				t.Errorf("expected statements of %v rows, got %v", test.expected, statements)
+			}
+
+		})
+
+	}
+
+}
+
+// Every insert mode writes the same values, datetimes in the location of the connection when given.
+func TestSQLRow(t *testing.T) {
+
+	rome, err := time.LoadLocation("Europe/Rome")
+
+	if err != nil {
+		t.Skip(err)
+	}
+
+	schema := gomulus.Schema{Columns: []gomulus.Column{
+		{Name: "day", Type: gomulus.TypeDate, Timezone: "America/New_York"},
+		{Name: "created_at", Type: gomulus.TypeDatetime},
+	}}
+
+	tests := []struct {
+		name     string
+		location *time.Location
+		expected []interface{}
+	}{
+		{"UTC connection", time.UTC, []interface{}{"2020-07-01", "2020-07-01 22:30:00"}},
+		{"connection in another location", rome, []interface{}{"2020-07-01", "2020-07-02 00:30:00"}},
+		{"no location", nil, []interface{}{time.Date(2020, 7, 1, 0, 0, 0, 0, time.UTC), time.Date(2020, 7, 1, 22, 30, 0, 0, time.UTC)}},
+	}
+
+	for _, test := range tests {
+
+		row, err := SQLRow([]interface{}{"2020-07-01", time.Date(2020, 7, 1, 22, 30, 0, 0, time.UTC)}, schema, test.location)
+
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", test.name, err.Error())
+			continue
+		}
+
+		if !reflect.DeepEqual(row, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, row)
+		}
+
+	}
+
+}
+
+// plainDialect only implements the DialectInterface.
+type plainDialect struct {
+	gomulus.DialectInterface
+}
+
+func TestSQLDestinationDialect(t *testing.T) {
+
+	gomulus.RegisterDialect("gomulus-plain", plainDialect{dialect.MysqlDialect{}})
+
+	tests := []struct {
+		name     string
+		option   string
+		value    interface{}
+		expected string
+	}{
+		{"on_conflict", "on_conflict", "ignore", "the `gomulus-fake` driver doesn't support on_conflict ignore"},
+		{"create_table", "create_table", "if_not_exists", "the `gomulus-fake` driver doesn't support create_table"},
+		{"swap", "load_strategy", "swap", "the `gomulus-fake` driver doesn't support load_strategy swap"},
+		{"ledger", "ledger", true, "the `gomulus-fake` driver doesn't support the ledger"},
+		{"loader of another driver", "insert_mode", "load_data", "invalid insert_mode `load_data`, expected row or multirow"},
+	}
+
+	for _, test := range tests {
+
+		config := map[string]interface{}{
+			"driver":   "gomulus-fake",
+			"dialect":  "gomulus-plain",
+			"database": "shop",
+			"table":    "orders",
+			"run_id":   "run",
+		}
+
+		config[test.option] = test.value
+
+		err := (&DefaultSQLDestination{}).New(config)
+
+		if err == nil || err.Error() != test.expected {
+			t.Errorf("%s: expected error `%s`, got %v", test.name, test.expected, err)
+		}
+
+	}
+
+}
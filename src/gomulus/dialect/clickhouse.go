@@ -0,0 +1,40 @@
+package gomulus
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ClickhouseDialect quotes identifiers with backslash escaped backticks and paginates with LIMIT offset, count.
+// The plugins compiling the driver in configure TLS with it.
+type ClickhouseDialect struct{}
+
+func (ClickhouseDialect) QuoteIdentifier(name string) string {
+
+	return "`" + strings.Replace(strings.Replace(name, "\\", "\\\\", -1), "`", "\\`", -1) + "`"
+
+}
+
+func (ClickhouseDialect) Placeholder(int) string {
+
+	return "?"
+
+}
+
+func (ClickhouseDialect) Paginate(query string, limit int, offset int) string {
+
+	return fmt.Sprintf("%s LIMIT %d, %d", query, offset, limit)
+
+}
+
+func (d ClickhouseDialect) ListTables(database string) string {
+
+	return "SHOW TABLES FROM " + d.QuoteIdentifier(database)
+
+}
+
+func (ClickhouseDialect) Truncate(table string) string {
+
+	return "TRUNCATE TABLE " + table
+
+}
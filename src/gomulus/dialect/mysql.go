@@ -0,0 +1,151 @@
+package gomulus
+
+import (
+	"crypto/tls"
+	"fmt"
+	"gomulus"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func init() {
+	gomulus.RegisterDialect("mysql", MysqlDialect{})
+}
+
+// MysqlDialect quotes identifiers with backticks and paginates with LIMIT offset, count.
+type MysqlDialect struct{}
+
+func (MysqlDialect) QuoteIdentifier(name string) string {
+
+	return "`" + strings.Replace(name, "`", "``", -1) + "`"
+
+}
+
+func (MysqlDialect) Placeholder(int) string {
+
+	return "?"
+
+}
+
+func (MysqlDialect) Paginate(query string, limit int, offset int) string {
+
+	return fmt.Sprintf("%s LIMIT %d, %d", query, offset, limit)
+
+}
+
+func (d MysqlDialect) ListTables(database string) string {
+
+	return "SHOW TABLES FROM " + d.QuoteIdentifier(database)
+
+}
+
+func (MysqlDialect) Truncate(table string) string {
+
+	return "TRUNCATE TABLE " + table
+
+}
+
+// ConfigureTLS registers the TLS configuration with the driver and sets the DSN to use it.
+func (MysqlDialect) ConfigureTLS(dsn string, name string, config *tls.Config) (string, error) {
+
+	if err := mysql.RegisterTLSConfig(name, config); err != nil {
+		return "", err
+	}
+
+	return MysqlTLSDSN(dsn, name)
+
+}
+
+// InsertConflict returns the INSERT IGNORE, REPLACE or INSERT ... ON DUPLICATE KEY UPDATE statement
+// of the values, by the on_conflict option, in the table with the column list given.
+func (d MysqlDialect) InsertConflict(onConflict string, table string, columns string, values string, updates []string) string {
+
+	switch onConflict {
+	case "ignore":
+		return fmt.Sprintf("INSERT IGNORE INTO %s%s VALUES %s", table, columns, values)
+	case "replace":
+		return fmt.Sprintf("REPLACE INTO %s%s VALUES %s", table, columns, values)
+	case "update":
+
+		assignments := make([]string, 0, len(updates))
+
+		for _, column := range updates {
+			assignments = append(assignments, fmt.Sprintf("%s = VALUES(%s)", d.QuoteIdentifier(column), d.QuoteIdentifier(column)))
+		}
+
+		return fmt.Sprintf("INSERT INTO %s%s VALUES %s ON DUPLICATE KEY UPDATE %s", table, columns, values, strings.Join(assignments, ", "))
+
+	}
+
+	return fmt.Sprintf("INSERT INTO %s%s VALUES %s", table, columns, values)
+
+}
+
+func (MysqlDialect) CreateLike(table string, like string) string {
+
+	return fmt.Sprintf("CREATE TABLE %s LIKE %s", table, like)
+
+}
+
+// Swap renames the table to the old one and the staging one to the table, in a single statement.
+func (MysqlDialect) Swap(table string, staging string, old string) string {
+
+	return fmt.Sprintf("RENAME TABLE %s TO %s, %s TO %s", table, old, staging, table)
+
+}
+
+// ColumnType returns the MySQL type closest to the logical type of the column.
+func (MysqlDialect) ColumnType(column gomulus.Column) string {
+
+	switch column.Type {
+	case gomulus.TypeInt:
+		return "BIGINT"
+	case gomulus.TypeUint:
+		return "BIGINT UNSIGNED"
+	case gomulus.TypeFloat:
+		return "DOUBLE"
+	case gomulus.TypeDecimal:
+		if column.Precision > 0 {
+			return fmt.Sprintf("DECIMAL(%d, %d)", column.Precision, column.Scale)
+		}
+		return "DECIMAL(65, 30)"
+	case gomulus.TypeBool:
+		return "TINYINT(1)"
+	case gomulus.TypeDate:
+		return "DATE"
+	case gomulus.TypeDatetime:
+		return "DATETIME(6)"
+	case gomulus.TypeJSON:
+		return "JSON"
+	case gomulus.TypeBytes:
+		if column.Length > 0 && column.Length <= 65535 {
+			return fmt.Sprintf("VARBINARY(%d)", column.Length)
+		}
+		return "LONGBLOB"
+	}
+
+	// VARCHAR up to the longest of utf8mb4 rows
+	if column.Length > 0 && column.Length <= 16383 {
+		return fmt.Sprintf("VARCHAR(%d)", column.Length)
+	}
+
+	return "LONGTEXT"
+
+}
+
+// PrimaryKey returns the query of the primary key columns of a table, given the database and the table.
+func (MysqlDialect) PrimaryKey() string {
+
+	return "SELECT `COLUMN_NAME` FROM `information_schema`.`KEY_COLUMN_USAGE` WHERE `TABLE_SCHEMA` = ? AND `TABLE_NAME` = ? " +
+		"AND `CONSTRAINT_NAME` = 'PRIMARY' ORDER BY `ORDINAL_POSITION`"
+
+}
+
+// References returns the query of the tables of a database and the tables their foreign keys reference, given the database.
+func (MysqlDialect) References() string {
+
+	return "SELECT `TABLE_NAME`, `REFERENCED_TABLE_NAME` FROM `information_schema`.`KEY_COLUMN_USAGE` WHERE `TABLE_SCHEMA` = ? " +
+		"AND `REFERENCED_TABLE_SCHEMA` = `TABLE_SCHEMA` AND `REFERENCED_TABLE_NAME` IS NOT NULL"
+
+}
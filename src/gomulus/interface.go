@@ -1,5 +1,10 @@
 package gomulus
 
+import (
+	"crypto/tls"
+	"net/url"
+)
+
 type SourceInterface interface {
 	New(map[string]interface{}) error
 	GetJobs() ([]map[string]interface{}, error)
@@ -10,4 +15,94 @@ type DestinationInterface interface {
 	New(map[string]interface{}) error
 	PreProcessData([][]interface{}) ([][]interface{}, error)
 	PersistData([][]interface{}) (int, error)
-}
\ No newline at end of file
+}
+
+type URLInterface interface {
+	ParseURL(*url.URL) (map[string]interface{}, error)
+}
+
+type SchemaSourceInterface interface {
+	GetSchema() (Schema, error)
+}
+
+type SchemaDestinationInterface interface {
+	SetSchema(Schema) error
+}
+
+type StreamingSourceInterface interface {
+	StreamData(map[string]interface{}, chan<- []interface{}) error
+}
+
+type StreamingDestinationInterface interface {
+	PersistStream(<-chan []interface{}) (int, error)
+}
+
+type JobIteratorSourceInterface interface {
+	IterateJobs(chan<- map[string]interface{}) error
+}
+
+type CloserInterface interface {
+	Close(bool) error
+}
+
+type ConfigurableInterface interface {
+	SetConfig(DriverConfig)
+}
+
+type TableSourceInterface interface {
+	GetTableSchema(string) (Schema, error)
+}
+
+type BatchDestinationInterface interface {
+	PersistBatch(Batch) (int, error)
+}
+
+type AcknowledgerInterface interface {
+	Acknowledge(map[string]interface{}) error
+}
+
+type FailureAcknowledgerInterface interface {
+	AcknowledgeFailure(map[string]interface{})
+}
+
+type BatchOnlyDestinationInterface interface {
+	BatchOnly() bool
+}
+
+type DeterministicJobsSourceInterface interface {
+	DeterministicJobs() bool
+}
+
+type DeterministicJobsDestinationInterface interface {
+	RequiresDeterministicJobs() bool
+}
+
+type DialectInterface interface {
+	QuoteIdentifier(string) string
+	Placeholder(int) string
+	Paginate(string, int, int) string
+	ListTables(string) string
+	Truncate(string) string
+}
+
+type TLSDialectInterface interface {
+	ConfigureTLS(string, string, *tls.Config) (string, error)
+}
+
+type ConflictDialectInterface interface {
+	InsertConflict(string, string, string, string, []string) string
+}
+
+type SwapDialectInterface interface {
+	CreateLike(string, string) string
+	Swap(string, string, string) string
+}
+
+type ColumnTypeDialectInterface interface {
+	ColumnType(Column) string
+}
+
+type KeyDialectInterface interface {
+	PrimaryKey() string
+	References() string
+}
@@ -0,0 +1,309 @@
+package gomulus
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const RedactedValue = "******"
+
+var interpolationRegexp = regexp.MustCompile(`\$?\$\{([^}]*)\}`)
+
+// options, and environment variables, whose resolved references are registered as secrets
+var secretKeyRegexp = regexp.MustCompile(`(?i)(pass|secret|token|credential)`)
+
+// password of a `user:password@protocol(address)` DSN, of the userinfo and of the password
+// parameter of a URL DSN
+var dsnPasswordRegexps = []*regexp.Regexp{
+	regexp.MustCompile(`^[^\s:@/]*:(.+)@[a-z0-9]*\(`),
+	regexp.MustCompile(`^[a-z][a-z0-9+.-]*://[^\s:@/]*:([^@/]*)@`),
+	regexp.MustCompile(`^[a-z][a-z0-9+.-]*://[^?]*\?(?:.*&)?password=([^&]*)`),
+}
+
+// registered secrets, longest first so a secret containing another one is fully redacted
+var secrets = make([]string, 0)
+
+var secretsMutex sync.RWMutex
+
+// Interpolate resolves `${ENV_VAR}`, `${ENV_VAR:-default}` and `${file:/path}`
+// references in every string of the configuration, nested driver options included.
+// `$${...}` escapes a literal `${...}`. Secret files, the references of password, secret
+// and token options and the variables named alike, defaults included, are registered as secrets.
+func (c *Config) Interpolate() error {
+
+	var err error
+
+	if err = c.Source.Interpolate(); err != nil {
+		return fmt.Errorf("source: %s", err.Error())
+	}
+
+	if err = c.Destination.Interpolate(); err != nil {
+		return fmt.Errorf("destination: %s", err.Error())
+	}
+
+	return nil
+
+}
+
+func (c *DriverConfig) Interpolate() error {
+
+	var err error
+	var options interface{}
+
+	if c.Driver, err = InterpolateString(c.Driver); err != nil {
+		return err
+	}
+
+	if c.Plugin, err = InterpolateString(c.Plugin); err != nil {
+		return err
+	}
+
+	if options, err = InterpolateValue(c.Options); err != nil {
+		return err
+	}
+
+	c.Options, _ = options.(map[string]interface{})
+
+	return nil
+
+}
+
+// String returns the JSON representation of the configuration with every resolved secret,
+// and the password of every DSN, redacted.
+func (c Config) String() string {
+
+	c.Source = c.Source.redacted()
+	c.Destination = c.Destination.redacted()
+
+	config, err := json.Marshal(c)
+
+	if err != nil {
+		return err.Error()
+	}
+
+	return string(config)
+
+}
+
+func (c DriverConfig) redacted() DriverConfig {
+
+	c.Driver = Redact(c.Driver)
+	c.Plugin = Redact(c.Plugin)
+	c.Options, _ = RedactValue(c.Options).(map[string]interface{})
+
+	return c
+
+}
+
+func InterpolateValue(value interface{}) (interface{}, error) {
+
+	return interpolateValue(value, false)
+
+}
+
+func interpolateValue(value interface{}, secret bool) (interface{}, error) {
+
+	var err error
+
+	switch v := value.(type) {
+
+	case string:
+
+		return interpolateString(v, secret)
+
+	case map[string]interface{}:
+
+		for key, item := range v {
+			if v[key], err = interpolateValue(item, secret || secretKeyRegexp.MatchString(key)); err != nil {
+				return nil, fmt.Errorf("option `%s`: %s", key, err.Error())
+			}
+		}
+
+	case []interface{}:
+
+		for i, item := range v {
+			if v[i], err = interpolateValue(item, secret); err != nil {
+				return nil, err
+			}
+		}
+
+	}
+
+	return value, nil
+
+}
+
+func InterpolateString(value string) (string, error) {
+
+	return interpolateString(value, false)
+
+}
+
+func interpolateString(value string, secret bool) (string, error) {
+
+	var err error
+
+	result := interpolationRegexp.ReplaceAllStringFunc(value, func(match string) string {
+
+		if err != nil {
+			return match
+		}
+
+		if strings.HasPrefix(match, "$$") {
+			return match[1:]
+		}
+
+		resolved, e := resolveReference(match[2:len(match)-1], secret)
+
+		if e != nil {
+			err = e
+			return match
+		}
+
+		return resolved
+
+	})
+
+	return result, err
+
+}
+
+func resolveReference(reference string, secret bool) (string, error) {
+
+	if strings.HasPrefix(reference, "file:") {
+
+		path := strings.TrimPrefix(reference, "file:")
+
+		content, err := ioutil.ReadFile(path)
+
+		if err != nil {
+			return "", fmt.Errorf("unable to read secret file `%s`: %s", path, err.Error())
+		}
+
+		value := strings.TrimRight(string(content), "\r\n")
+
+		AddSecret(value)
+
+		return value, nil
+
+	}
+
+	name := reference
+	fallback := ""
+	hasFallback := false
+
+	if i := strings.Index(reference, ":-"); i >= 0 {
+		name = reference[:i]
+		fallback = reference[i+2:]
+		hasFallback = true
+	}
+
+	secret = secret || secretKeyRegexp.MatchString(name)
+
+	value, ok := os.LookupEnv(name)
+
+	if (!ok || value == "") && hasFallback {
+		value = fallback
+	} else if !ok {
+		return "", fmt.Errorf("environment variable `%s` is not set", name)
+	}
+
+	if secret {
+		AddSecret(value)
+	}
+
+	return value, nil
+
+}
+
+// AddSecret registers a value which must never appear in logs.
+func AddSecret(secret string) {
+
+	if secret == "" {
+		return
+	}
+
+	secretsMutex.Lock()
+	defer secretsMutex.Unlock()
+
+	i := sort.Search(len(secrets), func(i int) bool {
+		return len(secrets[i]) <= len(secret)
+	})
+
+	for j := i; j < len(secrets) && len(secrets[j]) == len(secret); j++ {
+		if secrets[j] == secret {
+			return
+		}
+	}
+
+	secrets = append(secrets, "")
+	copy(secrets[i+1:], secrets[i:])
+	secrets[i] = secret
+
+}
+
+// RedactValue returns a copy of the given value with every registered secret, and the password
+// of every DSN, redacted.
+func RedactValue(value interface{}) interface{} {
+
+	switch v := value.(type) {
+
+	case string:
+
+		return RedactDSN(Redact(v))
+
+	case map[string]interface{}:
+
+		redacted := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			redacted[key] = RedactValue(item)
+		}
+		return redacted
+
+	case []interface{}:
+
+		redacted := make([]interface{}, len(v))
+		for i, item := range v {
+			redacted[i] = RedactValue(item)
+		}
+		return redacted
+
+	}
+
+	return value
+
+}
+
+// Redact replaces every registered secret found in the given string.
+func Redact(value string) string {
+
+	secretsMutex.RLock()
+	defer secretsMutex.RUnlock()
+
+	for _, secret := range secrets {
+		value = strings.Replace(value, secret, RedactedValue, -1)
+	}
+
+	return value
+
+}
+
+// RedactDSN masks the password of a `user:password@protocol(address)` DSN, or the userinfo
+// and `password` parameter of a URL DSN. Any other string is returned as is.
+func RedactDSN(value string) string {
+
+	for _, re := range dsnPasswordRegexps {
+		if m := re.FindStringSubmatchIndex(value); m != nil && m[3] > m[2] {
+			value = value[:m[2]] + RedactedValue + value[m[3]:]
+		}
+	}
+
+	return value
+
+}
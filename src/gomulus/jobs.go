@@ -0,0 +1,25 @@
+package gomulus
+
+// CollectJobs runs a job iterator to completion, returning every job generated.
+func CollectJobs(iterate func(chan<- map[string]interface{}) error) ([]map[string]interface{}, error) {
+
+	jobs := make([]map[string]interface{}, 0)
+	channel := make(chan map[string]interface{})
+	done := make(chan error, 1)
+
+	go func() {
+		done <- iterate(channel)
+		close(channel)
+	}()
+
+	for job := range channel {
+		jobs = append(jobs, job)
+	}
+
+	if err := <-done; err != nil {
+		return nil, err
+	}
+
+	return jobs, nil
+
+}
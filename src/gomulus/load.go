@@ -0,0 +1,302 @@
+package gomulus
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+)
+
+// LoadConfig reads a JSON, YAML or TOML configuration file, picked by extension.
+// Top level `include` files are merged beneath the including file, while a driver
+// block `extends` key inherits a block either from the merged document
+// (`connections.mysql`) or from another file (`shared.yaml#connections.mysql`).
+func LoadConfig(path string) (Config, error) {
+
+	var err error
+	var config Config
+	var document map[string]interface{}
+	var configJSON []byte
+
+	if path, err = filepath.Abs(path); err != nil {
+		return config, err
+	}
+
+	if document, err = loadDocument(path, make(map[string]bool, 0)); err != nil {
+		return config, err
+	}
+
+	for _, block := range []string{"source", "destination"} {
+		if document[block], err = extendBlock(document, document[block], path, make(map[string]bool, 0)); err != nil {
+			return config, fmt.Errorf("%s: %s", block, err.Error())
+		}
+	}
+
+	if configJSON, err = json.Marshal(document); err != nil {
+		return config, err
+	}
+
+	if err = json.Unmarshal(configJSON, &config); err != nil {
+		return config, err
+	}
+
+	return config, nil
+
+}
+
+func loadDocument(path string, visited map[string]bool) (map[string]interface{}, error) {
+
+	var err error
+	var content []byte
+	var raw interface{}
+
+	if path, err = filepath.Abs(path); err != nil {
+		return nil, err
+	}
+
+	if visited[path] {
+		return nil, fmt.Errorf("circular include of `%s`", path)
+	}
+
+	visited[path] = true
+	defer delete(visited, path)
+
+	if content, err = ioutil.ReadFile(path); err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, &raw)
+	case ".toml":
+		document := make(map[string]interface{}, 0)
+		err = toml.Unmarshal(content, &document)
+		raw = document
+	default:
+		err = json.Unmarshal(content, &raw)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse `%s`: %s", path, err.Error())
+	}
+
+	document, ok := normalizeValue(raw).(map[string]interface{})
+
+	if !ok {
+		return nil, fmt.Errorf("unable to parse `%s`: not an object", path)
+	}
+
+	includes := StringList(document["include"])
+
+	delete(document, "include")
+
+	merged := make(map[string]interface{}, 0)
+
+	for _, include := range includes {
+
+		if !filepath.IsAbs(include) {
+			include = filepath.Join(filepath.Dir(path), include)
+		}
+
+		included, err := loadDocument(include, visited)
+
+		if err != nil {
+			return nil, err
+		}
+
+		merged = mergeValues(merged, included).(map[string]interface{})
+
+	}
+
+	return mergeValues(merged, document).(map[string]interface{}), nil
+
+}
+
+// extendBlock resolves the `extends` chain of a block of the document read from path,
+// visited blocks being keyed by the absolute path of their file.
+func extendBlock(document map[string]interface{}, block interface{}, path string, visited map[string]bool) (interface{}, error) {
+
+	var err error
+	var parent interface{}
+
+	object, ok := block.(map[string]interface{})
+
+	if !ok {
+		return block, nil
+	}
+
+	extends, _ := object["extends"].(string)
+
+	if extends == "" {
+		return block, nil
+	}
+
+	file, key := "", extends
+
+	if i := strings.Index(extends, "#"); i >= 0 {
+		file, key = extends[:i], extends[i+1:]
+	}
+
+	source := document
+
+	if file != "" {
+
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(filepath.Dir(path), file)
+		}
+
+		if path, err = filepath.Abs(file); err != nil {
+			return nil, err
+		}
+
+	}
+
+	if visited[path+"#"+key] {
+		return nil, fmt.Errorf("circular extends of `%s`", extends)
+	}
+
+	visited[path+"#"+key] = true
+
+	if file != "" {
+		if source, err = loadDocument(path, make(map[string]bool, 0)); err != nil {
+			return nil, err
+		}
+	}
+
+	if parent, ok = lookupValue(source, key); !ok {
+		return nil, fmt.Errorf("unable to extend `%s`: block not found", extends)
+	}
+
+	if parent, err = extendBlock(source, parent, path, visited); err != nil {
+		return nil, err
+	}
+
+	delete(object, "extends")
+
+	return mergeValues(parent, object), nil
+
+}
+
+func lookupValue(document map[string]interface{}, key string) (interface{}, bool) {
+
+	var value interface{} = document
+
+	if key == "" {
+		return value, true
+	}
+
+	for _, part := range strings.Split(key, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = object[part]; !ok {
+			return nil, false
+		}
+	}
+
+	return value, true
+
+}
+
+// mergeValues deep merges objects, values of override taking precedence.
+// Lists and scalars are replaced as a whole.
+func mergeValues(base interface{}, override interface{}) interface{} {
+
+	baseObject, ok := base.(map[string]interface{})
+	overrideObject, ok2 := override.(map[string]interface{})
+
+	if !ok || !ok2 {
+		return copyValue(override)
+	}
+
+	merged := make(map[string]interface{}, len(baseObject)+len(overrideObject))
+
+	for key, value := range baseObject {
+		merged[key] = copyValue(value)
+	}
+
+	for key, value := range overrideObject {
+		if current, exists := merged[key]; exists {
+			merged[key] = mergeValues(current, value)
+		} else {
+			merged[key] = copyValue(value)
+		}
+	}
+
+	return merged
+
+}
+
+func copyValue(value interface{}) interface{} {
+
+	switch v := value.(type) {
+
+	case map[string]interface{}:
+
+		copied := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			copied[key] = copyValue(item)
+		}
+		return copied
+
+	case []interface{}:
+
+		copied := make([]interface{}, len(v))
+		for i, item := range v {
+			copied[i] = copyValue(item)
+		}
+		return copied
+
+	}
+
+	return value
+
+}
+
+// normalizeValue converts YAML and TOML decoded structures to the JSON ones.
+func normalizeValue(value interface{}) interface{} {
+
+	switch v := value.(type) {
+
+	case map[interface{}]interface{}:
+
+		normalized := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			normalized[fmt.Sprint(key)] = normalizeValue(item)
+		}
+		return normalized
+
+	case map[string]interface{}:
+
+		normalized := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			normalized[key] = normalizeValue(item)
+		}
+		return normalized
+
+	case []map[string]interface{}:
+
+		normalized := make([]interface{}, len(v))
+		for i, item := range v {
+			normalized[i] = normalizeValue(item)
+		}
+		return normalized
+
+	case []interface{}:
+
+		normalized := make([]interface{}, len(v))
+		for i, item := range v {
+			normalized[i] = normalizeValue(item)
+		}
+		return normalized
+
+	}
+
+	return value
+
+}
@@ -0,0 +1,148 @@
+package gomulus
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		files    map[string]string
+		expected Config
+		fails    string
+	}{
+		{
+			"json",
+			map[string]string{
+				"main.json": `{"timeout": 5, "source": {"driver": "csv", "options": {"path": "in.csv"}}, "destination": {"driver": "csv", "pool": 2}}`,
+			},
+			Config{Timeout: 5, Source: DriverConfig{Driver: "csv", Options: map[string]interface{}{"path": "in.csv"}}, Destination: DriverConfig{Driver: "csv", Pool: 2}},
+			"",
+		},
+		{
+			"yaml",
+			map[string]string{
+				"main.yaml": "chunk_size: 100\nsource:\n  driver: mysql\n  options:\n    tables: [a, b]\n    limit: 10\ndestination:\n  driver: csv\n",
+			},
+			Config{ChunkSize: 100, Source: DriverConfig{Driver: "mysql", Options: map[string]interface{}{"tables": []interface{}{"a", "b"}, "limit": float64(10)}}, Destination: DriverConfig{Driver: "csv"}},
+			"",
+		},
+		{
+			"toml",
+			map[string]string{
+				"main.toml": "timeout = 3\n[source]\ndriver = \"mysql\"\n[source.options]\nhost = \"localhost\"\n[destination]\ndriver = \"csv\"\n",
+			},
+			Config{Timeout: 3, Source: DriverConfig{Driver: "mysql", Options: map[string]interface{}{"host": "localhost"}}, Destination: DriverConfig{Driver: "csv"}},
+			"",
+		},
+		{
+			"includes merged in order beneath the including file",
+			map[string]string{
+				"main.yaml":       "include: [first.json, sub/second.yaml]\nsource:\n  options:\n    limit: 1\n",
+				"first.json":      `{"timeout": 1, "source": {"driver": "csv", "options": {"path": "first", "limit": 9}}, "destination": {"driver": "csv"}}`,
+				"sub/second.yaml": "include: third.toml\ntimeout: 2\nsource:\n  options:\n    path: second\n",
+				"sub/third.toml":  "chunk_size = 7\n",
+			},
+			Config{Timeout: 2, ChunkSize: 7, Source: DriverConfig{Driver: "csv", Options: map[string]interface{}{"path": "second", "limit": float64(1)}}, Destination: DriverConfig{Driver: "csv"}},
+			"",
+		},
+		{
+			"circular include",
+			map[string]string{
+				"main.json":  `{"include": "other.json"}`,
+				"other.json": `{"include": "./main.json"}`,
+			},
+			Config{},
+			"circular include",
+		},
+		{
+			"extends from the document and from another file",
+			map[string]string{
+				"main.yaml":   "connections:\n  mysql:\n    extends: shared.yaml#base\n    options:\n      host: main\nsource:\n  extends: connections.mysql\n  options:\n    table: orders\ndestination:\n  extends: shared.yaml#base\n",
+				"shared.yaml": "base:\n  driver: mysql\n  options:\n    host: shared\n    user: root\n",
+			},
+			Config{Source: DriverConfig{Driver: "mysql", Options: map[string]interface{}{"host": "main", "user": "root", "table": "orders"}}, Destination: DriverConfig{Driver: "mysql", Options: map[string]interface{}{"host": "shared", "user": "root"}}},
+			"",
+		},
+		{
+			"same key extended in different files",
+			map[string]string{
+				"main.yaml":   "base:\n  extends: shared.yaml#conn\n  options:\n    table: orders\nsource:\n  extends: base\n",
+				"shared.yaml": "base:\n  driver: mysql\nconn:\n  extends: base\n  options:\n    host: shared\n",
+			},
+			Config{Source: DriverConfig{Driver: "mysql", Options: map[string]interface{}{"host": "shared", "table": "orders"}}},
+			"",
+		},
+		{
+			"circular extends through differently written paths",
+			map[string]string{
+				"main.yaml":   "source:\n  extends: shared.yaml#a\n",
+				"shared.yaml": "a:\n  extends: ./sub/../shared.yaml#b\nb:\n  extends: a\n",
+			},
+			Config{},
+			"circular extends",
+		},
+		{
+			"missing extended block",
+			map[string]string{
+				"main.yaml": "source:\n  extends: connections.none\n",
+			},
+			Config{},
+			"block not found",
+		},
+	}
+
+	for _, test := range tests {
+
+		t.Run(strings.Replace(test.name, " ", "_", -1), func(t *testing.T) {
+
+			dir, err := ioutil.TempDir("", "gomulus")
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer os.RemoveAll(dir)
+
+			main := ""
+
+			for name, content := range test.files {
+				if strings.HasPrefix(name, "main.") {
+					main = name
+				}
+				if err = os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err = ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			config, err := LoadConfig(filepath.Join(dir, main))
+
+			if test.fails != "" {
+				if err == nil || !strings.Contains(err.Error(), test.fails) {
+					t.Errorf("expected error `%s`, got %v", test.fails, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(config, test.expected) {
+				t.Errorf("expected %#v, got %#v", test.expected, config)
+			}
+
+		})
+
+	}
+
+}
@@ -0,0 +1,231 @@
+package gomulus
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var sourceDrivers = make(map[string]func() SourceInterface, 0)
+
+var destinationDrivers = make(map[string]func() DestinationInterface, 0)
+
+var dialects = make(map[string]DialectInterface, 0)
+
+var driversMutex sync.RWMutex
+
+// RegisterSource makes a source driver available under the given name.
+// Plugins can call it from their init function to be usable by name or URL scheme.
+func RegisterSource(name string, factory func() SourceInterface) {
+
+	driversMutex.Lock()
+	sourceDrivers[name] = factory
+	driversMutex.Unlock()
+
+}
+
+// RegisterDestination makes a destination driver available under the given name.
+func RegisterDestination(name string, factory func() DestinationInterface) {
+
+	driversMutex.Lock()
+	destinationDrivers[name] = factory
+	driversMutex.Unlock()
+
+}
+
+// RegisterDialect makes an SQL dialect available to the "sql" drivers under the given name,
+// usually the one of its database/sql driver.
+func RegisterDialect(name string, dialect DialectInterface) {
+
+	driversMutex.Lock()
+	dialects[name] = dialect
+	driversMutex.Unlock()
+
+}
+
+func GetDialect(name string) (DialectInterface, bool) {
+
+	driversMutex.RLock()
+	dialect, ok := dialects[name]
+	driversMutex.RUnlock()
+
+	return dialect, ok
+
+}
+
+func NewSource(name string) (SourceInterface, bool) {
+
+	driversMutex.RLock()
+	factory, ok := sourceDrivers[name]
+	driversMutex.RUnlock()
+
+	if !ok {
+		return nil, false
+	}
+
+	return factory(), true
+
+}
+
+func NewDestination(name string) (DestinationInterface, bool) {
+
+	driversMutex.RLock()
+	factory, ok := destinationDrivers[name]
+	driversMutex.RUnlock()
+
+	if !ok {
+		return nil, false
+	}
+
+	return factory(), true
+
+}
+
+// ParseSourceURL builds a source driver configuration from an URL whose scheme is a registered driver name.
+func ParseSourceURL(rawURL string) (DriverConfig, error) {
+
+	var err error
+	var config DriverConfig
+	var parsed *url.URL
+
+	if parsed, err = url.Parse(rawURL); err != nil {
+		return config, err
+	}
+
+	source, ok := NewSource(parsed.Scheme)
+
+	if !ok {
+		return config, fmt.Errorf("no source driver found under the name `%s`", parsed.Scheme)
+	}
+
+	parser, ok := source.(URLInterface)
+
+	if !ok {
+		return config, fmt.Errorf("source driver `%s` does not support URLs", parsed.Scheme)
+	}
+
+	config.Driver = parsed.Scheme
+
+	if config.Options, err = parser.ParseURL(parsed); err != nil {
+		return config, err
+	}
+
+	return config, nil
+
+}
+
+// ParseDestinationURL builds a destination driver configuration from an URL whose scheme is a registered driver name.
+func ParseDestinationURL(rawURL string) (DriverConfig, error) {
+
+	var err error
+	var config DriverConfig
+	var parsed *url.URL
+
+	if parsed, err = url.Parse(rawURL); err != nil {
+		return config, err
+	}
+
+	destination, ok := NewDestination(parsed.Scheme)
+
+	if !ok {
+		return config, fmt.Errorf("no destination driver found under the name `%s`", parsed.Scheme)
+	}
+
+	parser, ok := destination.(URLInterface)
+
+	if !ok {
+		return config, fmt.Errorf("destination driver `%s` does not support URLs", parsed.Scheme)
+	}
+
+	config.Driver = parsed.Scheme
+
+	if config.Options, err = parser.ParseURL(parsed); err != nil {
+		return config, err
+	}
+
+	return config, nil
+
+}
+
+// URLOptions converts the URL query to driver options, guessing booleans and numbers
+// the same way they would have been decoded from a JSON configuration.
+func URLOptions(u *url.URL) map[string]interface{} {
+
+	options := make(map[string]interface{}, 0)
+
+	for key, values := range u.Query() {
+
+		if len(values) == 1 {
+			options[key] = urlValue(key, values[0])
+			continue
+		}
+
+		list := make([]interface{}, 0, len(values))
+		for _, value := range values {
+			list = append(list, urlValue(key, value))
+		}
+		options[key] = list
+
+	}
+
+	return options
+
+}
+
+// URLSegments returns the non empty segments of the URL path.
+func URLSegments(u *url.URL) []string {
+
+	segments := make([]string, 0)
+
+	for _, segment := range strings.Split(u.Path, "/") {
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+
+	return segments
+
+}
+
+// NumericURLOptions lists the URL query options read as numbers, any other option is read as a
+// string, or as a bool when it is `true` or `false`.
+var NumericURLOptions = map[string]bool{
+	"pool":                     true,
+	"port":                     true,
+	"count":                    true,
+	"offset":                   true,
+	"limit":                    true,
+	"max_open_conns":           true,
+	"max_idle_conns":           true,
+	"conn_max_lifetime":        true,
+	"throttle_interval":        true,
+	"throttle_replica_lag":     true,
+	"throttle_threads_running": true,
+	"watermark_overlap":        true,
+	"server_id":                true,
+	"flush_interval":           true,
+}
+
+func urlValue(key string, value string) interface{} {
+
+	switch value {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+
+	if !NumericURLOptions[key] {
+		return value
+	}
+
+	if f, err := strconv.ParseFloat(value, 64); err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) {
+		return f
+	}
+
+	return value
+
+}
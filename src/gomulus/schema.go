@@ -0,0 +1,73 @@
+package gomulus
+
+import "time"
+
+type Type string
+
+const (
+	TypeInt      Type = "int"
+	TypeUint     Type = "uint"
+	TypeFloat    Type = "float"
+	TypeDecimal  Type = "decimal"
+	TypeString   Type = "string"
+	TypeBytes    Type = "bytes"
+	TypeBool     Type = "bool"
+	TypeDate     Type = "date"
+	TypeDatetime Type = "datetime"
+	TypeJSON     Type = "json"
+	TypeNull     Type = "null"
+)
+
+// Column describes a column of a source. Length is the maximum length of character and binary
+// columns, Definition the full column type as declared in the source database, e.g. `varchar(255)`.
+type Column struct {
+	Name         string `json:"name"`
+	Type         Type   `json:"type"`
+	Nullable     bool   `json:"nullable,omitempty"`
+	Precision    int    `json:"precision,omitempty"`
+	Scale        int    `json:"scale,omitempty"`
+	Length       int    `json:"length,omitempty"`
+	DatabaseType string `json:"database_type,omitempty"`
+	Definition   string `json:"definition,omitempty"`
+	Timezone     string `json:"timezone,omitempty"`
+}
+
+// Location returns the timezone of date and time values lacking one, UTC by default.
+func (c Column) Location() (*time.Location, error) {
+
+	if c.Timezone == "" {
+		return time.UTC, nil
+	}
+
+	return time.LoadLocation(c.Timezone)
+
+}
+
+type Schema struct {
+	Columns []Column `json:"columns"`
+}
+
+func (s Schema) Names() []string {
+
+	names := make([]string, 0, len(s.Columns))
+
+	for _, column := range s.Columns {
+		names = append(names, column.Name)
+	}
+
+	return names
+
+}
+
+// Index returns the position of the named column, or -1 when missing.
+func (s Schema) Index(name string) int {
+
+	for i, column := range s.Columns {
+		if column.Name == name {
+			return i
+		}
+	}
+
+	return -1
+
+}
@@ -3,14 +3,22 @@ package gomulus
 import (
 	"bufio"
 	"encoding/csv"
+	"fmt"
 	"gomulus"
 	"io"
 	"math"
+	"net/url"
 	"os"
 	"path/filepath"
-	"strings"
+	"strconv"
 )
 
+func init() {
+	gomulus.RegisterSource("csv", func() gomulus.SourceInterface {
+		return &DefaultCSVSource{}
+	})
+}
+
 type DefaultCSVSource struct {
 	Config  gomulus.DriverConfig
 	File    *os.File
@@ -20,6 +28,15 @@ type DefaultCSVSource struct {
 	EOL     string
 	Comma   string
 	Columns []int
+	Header  []string
+	Types   []gomulus.Type
+	Null    *string
+}
+
+func (s *DefaultCSVSource) SetConfig(config gomulus.DriverConfig) {
+
+	s.Config = config
+
 }
 
 func (s *DefaultCSVSource) New(config map[string]interface{}) error {
@@ -31,6 +48,8 @@ func (s *DefaultCSVSource) New(config map[string]interface{}) error {
 	var limit, _ = config["limit"].(float64)
 	var offset, _ = config["offset"].(float64)
 	var path, _ = config["path"].(string)
+	var header, _ = config["header"].(bool)
+	var types, _ = config["types"].([]interface{})
 	columns, ok := config["columns"].([]interface{})
 
 	if eol == "" {
@@ -64,89 +83,225 @@ func (s *DefaultCSVSource) New(config map[string]interface{}) error {
 		}
 	}
 
+	for _, t := range types {
+		tt, _ := t.(string)
+		s.Types = append(s.Types, gomulus.Type(tt))
+	}
+
+	if null, ok := config["null"].(string); ok {
+		s.Null = &null
+	}
+
+	if header {
+
+		if s.Header, err = s.readHeader(); err != nil {
+			return err
+		}
+
+		if s.Offset == 0 {
+			s.Offset = 1
+		}
+
+	}
+
 	return nil
 
 }
 
+func (s *DefaultCSVSource) GetSchema() (gomulus.Schema, error) {
+
+	schema := gomulus.Schema{Columns: make([]gomulus.Column, 0, len(s.Header))}
+
+	for i, name := range s.Header {
+		if len(s.Columns) > 0 && InSliceInt(i, s.Columns) || len(s.Columns) == 0 {
+			schema.Columns = append(schema.Columns, gomulus.Column{
+				Name:     name,
+				Type:     s.columnType(len(schema.Columns)),
+				Nullable: s.Null != nil,
+			})
+		}
+	}
+
+	return schema, nil
+
+}
+
+// columnType returns the declared logical type of the n-th selected column, string by default.
+func (s *DefaultCSVSource) columnType(n int) gomulus.Type {
+
+	if n < len(s.Types) && s.Types[n] != "" {
+		return s.Types[n]
+	}
+
+	return gomulus.TypeString
+
+}
+
+// value converts a raw field of the n-th selected column to its declared logical type.
+// Fields of untyped columns are kept as raw bytes.
+func (s *DefaultCSVSource) value(field string, n int) (interface{}, error) {
+
+	if s.Null != nil && field == *s.Null {
+		return nil, nil
+	}
+
+	if n >= len(s.Types) || s.Types[n] == "" {
+		return []byte(field), nil
+	}
+
+	return gomulus.Convert(field, s.Types[n])
+
+}
+
+func (s *DefaultCSVSource) readHeader() ([]string, error) {
+
+	file, err := os.Open(s.Path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.Comma = []rune(s.Comma)[0]
+
+	header, err := reader.Read()
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to read csv header: %s", err.Error())
+	}
+
+	return header, nil
+
+}
+
+func (s *DefaultCSVSource) ParseURL(u *url.URL) (map[string]interface{}, error) {
+
+	options := gomulus.URLOptions(u)
+
+	if u.Opaque != "" {
+		options["path"] = u.Opaque
+	} else {
+		options["path"] = u.Host + u.Path
+	}
+
+	if options["path"] == "" {
+		return nil, fmt.Errorf("invalid csv URL, expected csv:///path/to/file.csv")
+	}
+
+	// column indexes, the only numeric list of the driver
+	if columns, ok := u.Query()["columns"]; ok {
+
+		list := make([]interface{}, 0, len(columns))
+
+		for _, column := range columns {
+
+			index, err := strconv.Atoi(column)
+
+			if err != nil {
+				return nil, fmt.Errorf("invalid csv column index `%s`", column)
+			}
+
+			list = append(list, float64(index))
+
+		}
+
+		options["columns"] = list
+
+	}
+
+	return options, nil
+
+}
+
 func (s *DefaultCSVSource) GetJobs() ([]map[string]interface{}, error) {
 
-	var jobs = make([]map[string]interface{}, 0)
-	var lines = make(map[int]int, 0)
+	return gomulus.CollectJobs(s.IterateJobs)
 
-	offset := s.Offset
+}
+
+// IterateJobs scans the file once, sending the byte range of every `limit` lines as soon as it is known.
+func (s *DefaultCSVSource) IterateJobs(jobs chan<- map[string]interface{}) error {
+
+	from := -1
 	count := 0
 	total := 0
-	scanner := bufio.NewScanner(s.File)
+	scanner := bufio.NewScanner(io.NewSectionReader(s.File, 0, math.MaxInt64))
 
-	lines[0] = 0
-	for scanner.Scan() {
-		count++
-		length := total + len([]byte(scanner.Text())) + len([]byte(s.EOL))
-		lines[count] = length
-		total = length
-		if scanner.Err() != nil {
-			return nil, scanner.Err()
-		}
+	if s.Offset == 0 {
+		from = 0
 	}
 
-	stop := false
-
-	for true {
+	for scanner.Scan() {
 
-		from := lines[offset]
-		to := lines[offset+s.Limit]
+		count++
+		total += len(scanner.Bytes()) + len([]byte(s.EOL))
 
-		if stop {
-			break
+		if count == s.Offset {
+			from = total
+			continue
 		}
 
-		if to == 0 || to > total {
-			to = total
-			stop = true
+		if from >= 0 && (count-s.Offset)%s.Limit == 0 {
+			jobs <- map[string]interface{}{
+				"from": from,
+				"to":   total,
+			}
+			from = total
 		}
 
-		jobs = append(jobs, map[string]interface{}{
-			"from": from,
-			"to":   to,
-		})
+	}
 
-		offset = offset + s.Limit
+	if scanner.Err() != nil {
+		return scanner.Err()
+	}
 
+	if from >= 0 && total > from {
+		jobs <- map[string]interface{}{
+			"from": from,
+			"to":   total,
+		}
 	}
 
-	return jobs, nil
+	return nil
 
 }
 
 func (s *DefaultCSVSource) FetchData(job map[string]interface{}) ([][]interface{}, error) {
 
-	var err error
 	var data [][]interface{}
-	var file *os.File
-	var from, _ = job["from"].(int)
-	var to, _ = job["to"].(int)
 
-	if false {
+	err := s.fetch(job, func(row []interface{}) error {
+		data = append(data, row)
+		return nil
+	})
 
-		if file, err = os.Open(s.Path); err != nil {
-			return nil, err
-		}
+	if err != nil {
+		return nil, err
+	}
 
-		defer file.Close()
+	return data, nil
 
-	} else {
+}
 
-		file = s.File
+func (s *DefaultCSVSource) StreamData(job map[string]interface{}, rows chan<- []interface{}) error {
 
-	}
+	return s.fetch(job, func(row []interface{}) error {
+		rows <- row
+		return nil
+	})
 
-	buffer := make([]byte, to-from)
+}
 
-	if _, err = file.ReadAt(buffer, int64(from)); err != nil && err != io.EOF {
-		return nil, err
-	}
+// fetch reads the lines of the job byte range one at a time.
+func (s *DefaultCSVSource) fetch(job map[string]interface{}, callback func([]interface{}) error) error {
 
-	reader := csv.NewReader(strings.NewReader(string(buffer)))
+	var from, _ = job["from"].(int)
+	var to, _ = job["to"].(int)
+	var count = 0
+
+	reader := csv.NewReader(bufio.NewReader(io.NewSectionReader(s.File, int64(from), int64(to-from))))
 	reader.Comma = []rune(s.Comma)[0]
 
 	for {
@@ -162,17 +317,31 @@ func (s *DefaultCSVSource) FetchData(job map[string]interface{}) ([][]interface{
 			continue
 		}
 
+		count++
+
 		for i, c := range columns {
 			if len(s.Columns) > 0 && InSliceInt(i, s.Columns) || len(s.Columns) == 0 {
-				slice = append(slice, []byte(c))
+				value, err := s.value(c, len(slice))
+				if err != nil {
+					return fmt.Errorf("row %d, column %d: %s", count, i, err.Error())
+				}
+				slice = append(slice, value)
 			}
 		}
 
-		data = append(data, slice)
+		if err = callback(slice); err != nil {
+			return err
+		}
 
 	}
 
-	return data, nil
+	return nil
+
+}
+
+func (s *DefaultCSVSource) Close(success bool) error {
+
+	return s.File.Close()
 
 }
 
@@ -0,0 +1,225 @@
+package gomulus
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"gomulus"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeResult is the answer of a fakeHandler, a nil one being an empty result set.
+type fakeResult struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+type fakeHandler func(query string, args []driver.Value) (*fakeResult, error)
+
+var fakeHandlers = make(map[string]fakeHandler, 0)
+
+var fakeMutex sync.Mutex
+
+func init() {
+	sql.Register("gomulus-fake", fakeDriver{})
+}
+
+// openFake returns a database answering every query with the given handler.
+func openFake(t *testing.T, handler fakeHandler) *sql.DB {
+
+	fakeMutex.Lock()
+	fakeHandlers[t.Name()] = handler
+	fakeMutex.Unlock()
+
+	db, err := sql.Open("gomulus-fake", t.Name())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+
+	fakeMutex.Lock()
+	defer fakeMutex.Unlock()
+
+	return fakeConn{handler: fakeHandlers[name]}, nil
+
+}
+
+type fakeConn struct {
+	handler fakeHandler
+}
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) {
+
+	return nil, errors.New("prepared statements are not supported")
+
+}
+
+func (c fakeConn) Close() error {
+
+	return nil
+
+}
+
+func (c fakeConn) Begin() (driver.Tx, error) {
+
+	return nil, errors.New("transactions are not supported")
+
+}
+
+func (c fakeConn) QueryContext(ctx context.Context, query string, named []driver.NamedValue) (driver.Rows, error) {
+
+	args := make([]driver.Value, 0, len(named))
+
+	for _, arg := range named {
+		args = append(args, arg.Value)
+	}
+
+	result, err := c.handler(query, args)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if result == nil {
+		result = &fakeResult{columns: []string{"value"}}
+	}
+
+	return &fakeRows{result: result}, nil
+
+}
+
+type fakeRows struct {
+	result *fakeResult
+	next   int
+}
+
+func (r *fakeRows) Columns() []string {
+
+	return r.result.columns
+
+}
+
+func (r *fakeRows) Close() error {
+
+	return nil
+
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+
+	if r.next >= len(r.result.rows) {
+		return io.EOF
+	}
+
+	copy(dest, r.result.rows[r.next])
+	r.next++
+
+	return nil
+
+}
+
+func collectJobs(t *testing.T, iterate func(chan<- map[string]interface{}) error) []map[string]interface{} {
+
+	jobs, err := gomulus.CollectJobs(iterate)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return jobs
+
+}
+
+func TestThrottleBusy(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		throttle Throttle
+		running  string
+		lag      driver.Value
+		legacy   bool
+		expected bool
+	}{
+		{"disabled", Throttle{}, "100", int64(100), false, false},
+		{"below threads", Throttle{ThreadsRunning: 10}, "10", nil, false, false},
+		{"above threads", Throttle{ThreadsRunning: 10}, "11", nil, false, true},
+		{"below lag", Throttle{ReplicaLag: 5}, "0", []byte("5"), false, false},
+		{"above lag", Throttle{ReplicaLag: 5}, "0", []byte("6"), false, true},
+		{"above lag before MySQL 8.0.22", Throttle{ReplicaLag: 5}, "0", int64(6), true, true},
+		{"stopped replication", Throttle{ReplicaLag: 5}, "0", nil, false, true},
+	}
+
+	for _, test := range tests {
+
+		t.Run(strings.Replace(test.name, " ", "_", -1), func(t *testing.T) {
+
+			db := openFake(t, func(query string, args []driver.Value) (*fakeResult, error) {
+
+				switch query {
+				case "SHOW GLOBAL STATUS LIKE 'Threads_running'":
+					return &fakeResult{columns: []string{"Variable_name", "Value"}, rows: [][]driver.Value{{"Threads_running", test.running}}}, nil
+				case "SHOW REPLICA STATUS":
+					if test.legacy {
+						return nil, errors.New("syntax error")
+					}
+					return &fakeResult{columns: []string{"Replica_IO_State", "Seconds_Behind_Source"}, rows: [][]driver.Value{{"", test.lag}}}, nil
+				case "SHOW SLAVE STATUS":
+					return &fakeResult{columns: []string{"Slave_IO_State", "Seconds_Behind_Master"}, rows: [][]driver.Value{{"", test.lag}}}, nil
+				}
+
+				return nil, fmt.Errorf("unexpected query %s", query)
+
+			})
+
+			busy, err := test.throttle.Busy(db)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if busy != test.expected {
+				t.Errorf("expected busy %v, got %v", test.expected, busy)
+			}
+
+		})
+
+	}
+
+}
+
+func TestThrottleWait(t *testing.T) {
+
+	checks := 0
+
+	db := openFake(t, func(query string, args []driver.Value) (*fakeResult, error) {
+		checks++
+		running := "50"
+		if checks == 3 {
+			running = "1"
+		}
+		return &fakeResult{columns: []string{"Variable_name", "Value"}, rows: [][]driver.Value{{"Threads_running", running}}}, nil
+	})
+
+	if err := (Throttle{ThreadsRunning: 10, Interval: time.Millisecond}).Wait(db); err != nil {
+		t.Fatal(err)
+	}
+
+	if checks != 3 {
+		t.Errorf("expected 3 checks, got %d", checks)
+	}
+
+}
@@ -0,0 +1,1211 @@
+package gomulus
+
+import (
+	"context"
+	"crypto/sha1"
+	"database/sql"
+	"errors"
+	"fmt"
+	"gomulus"
+	"math"
+	"path"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+func init() {
+	gomulus.RegisterSource("sql", func() gomulus.SourceInterface {
+		return &DefaultSQLSource{}
+	})
+}
+
+// SchemaReaderInterface reads the schema of the columns selected by a source, in place of the
+// Go types of the driver, e.g. the types declared in MySQL for the "mysql" source.
+type SchemaReaderInterface interface {
+	ReadSchema(*DefaultSQLSource) (gomulus.Schema, error)
+}
+
+// DefaultSQLSource reads a table, a query or the tables of a database through any database/sql driver,
+// in the SQL of the dialect registered under the driver name or the dialect option.
+type DefaultSQLSource struct {
+	Config   gomulus.DriverConfig
+	DB       *sql.DB
+	Dialect  gomulus.DialectInterface
+	Limit    int
+	Count    int
+	Offset   int
+	Table    string
+	Database string
+	// selected columns, quoted when given as a list
+	Columns   string
+	Schema    gomulus.Schema
+	SplitBy   string
+	SplitMode string
+	Where     string
+	Query     string
+	Watermark Watermark
+	// primary key of the table, ordering the rows of the jobs paginated by offset
+	PrimaryKey []string
+	// tables exported in whole database mode, referenced tables first, and the jobs of each
+	// table not yet acknowledged, waited for before the tables referencing it
+	Tables      []string
+	Schemas     map[string]gomulus.Schema
+	PrimaryKeys map[string][]string
+	References  map[string][]string
+	Progress    *TableProgress
+	// conditions and arguments added to the `where` option
+	Conditions []string
+	WhereArgs  []interface{}
+	// connection generating the jobs, e.g. within the snapshot of the "mysql" source, the DB otherwise
+	Meta         gomulus.Querier
+	SchemaReader SchemaReaderInterface
+	// jobs are fetched from every host in turn, the first one also reading the metadata
+	Hosts    []*sql.DB
+	NextHost uint32
+	// statements run on every host at the end of the run
+	PostSQL []string
+}
+
+// TableProgress counts the jobs of every table generated and not yet acknowledged.
+type TableProgress struct {
+	Mutex     sync.Mutex
+	Pending   map[string]int
+	Generated map[string]bool
+	Done      map[string]chan struct{}
+}
+
+type Watermark struct {
+	Column    string
+	StateFile string
+	StateKey  string
+	High      string
+}
+
+func (s *DefaultSQLSource) SetConfig(config gomulus.DriverConfig) {
+
+	s.Config = config
+
+}
+
+func (s *DefaultSQLSource) New(config map[string]interface{}) error {
+
+	var driver, _ = config["driver"].(string)
+	var dialectName, _ = config["dialect"].(string)
+
+	if driver == "" {
+		return errors.New("no database/sql driver given, set the driver option")
+	}
+
+	if dialectName == "" {
+		dialectName = driver
+	}
+
+	dialect, ok := gomulus.GetDialect(dialectName)
+
+	if !ok {
+		return fmt.Errorf("no dialect found under the name `%s`", dialectName)
+	}
+
+	// one connection per worker, and one generating the jobs
+	if err := s.open(driver, dialect, gomulus.StringList(config["host"]), int(math.Max(1, float64(s.Config.Pool)))+1, config); err != nil {
+		return err
+	}
+
+	if err := s.prepare(config); err != nil {
+		s.release()
+		return err
+	}
+
+	return nil
+
+}
+
+// open validates the options shared by the SQL sources and connects to every host through the driver,
+// with pools of the given size, unlimited when 0.
+func (s *DefaultSQLSource) open(driver string, dialect gomulus.DialectInterface, endpoints []string, poolSize int, config map[string]interface{}) (err error) {
+
+	var db *sql.DB
+	var database, _ = config["database"].(string)
+	var table, _ = config["table"].(string)
+	var limit, _ = config["limit"].(float64)
+	var offset, _ = config["offset"].(float64)
+	var columns, raw = config["columns"].(string)
+	var splitBy, _ = config["split_by"].(string)
+	var splitMode, _ = config["split_mode"].(string)
+	var where, _ = config["where"].(string)
+	var query, _ = config["query"].(string)
+	var watermarkColumn, _ = config["watermark_column"].(string)
+	var postSQL = gomulus.StringList(config["post_sql"])
+	var include = gomulus.StringList(config["tables"])
+	var exclude = gomulus.StringList(config["exclude_tables"])
+	var tables = make([]string, 0)
+
+	query = strings.TrimRight(strings.TrimSpace(query), ";")
+
+	if ok, _ := regexp.MatchString(`(?is)^(SELECT|WITH)\s`, query); !ok && query != "" {
+		return errors.New("invalid query, expected a SELECT statement")
+	}
+
+	if ok, _ := regexp.MatchString(`^[\p{L}_][\p{L}\p{N}@$#_]{0,127}$`, database); !ok && query == "" {
+		return errors.New(fmt.Sprintf("invalid database name `%s`", database))
+	}
+
+	if ok, _ := regexp.MatchString(`^[\p{L}_][\p{L}\p{N}@$#_]{0,127}$`, table); !ok && query == "" && len(include) == 0 {
+		return errors.New(fmt.Sprintf("invalid table name `%s`", table))
+	}
+
+	if len(include) > 0 && (query != "" || watermarkColumn != "") {
+		return errors.New("tables cannot be combined with the query and watermark_column options")
+	}
+
+	if ok, _ := regexp.MatchString(`^[\p{L}_][\p{L}\p{N}@$#_]{0,127}$`, splitBy); !ok && splitBy != "" {
+		return errors.New(fmt.Sprintf("invalid split_by column name `%s`", splitBy))
+	}
+
+	if ok, _ := regexp.MatchString(`^[\p{L}_][\p{L}\p{N}@$#_]{0,127}$`, watermarkColumn); !ok && watermarkColumn != "" {
+		return errors.New(fmt.Sprintf("invalid watermark_column name `%s`", watermarkColumn))
+	}
+
+	if splitMode != "" && splitMode != "range" && splitMode != "adaptive" {
+		return fmt.Errorf("invalid split_mode `%s`, expected `range` or `adaptive`", splitMode)
+	}
+
+	// a list of columns is quoted, a string is selected as it is, e.g. with expressions
+	selected := strings.TrimSpace(columns)
+
+	if !raw {
+
+		quoted := make([]string, 0)
+
+		for _, column := range gomulus.StringList(config["columns"]) {
+			quoted = append(quoted, dialect.QuoteIdentifier(column))
+		}
+
+		selected = strings.Join(quoted, ", ")
+
+	}
+
+	if selected == "" {
+		selected = "*"
+	}
+
+	if len(endpoints) == 0 {
+		endpoints = append(endpoints, "")
+	}
+
+	// the connections are released when a later step fails
+	defer func() {
+		if err != nil {
+			s.release()
+		}
+	}()
+
+	for _, endpoint := range endpoints {
+
+		if db, err = gomulus.Connect(driver, dialect, endpoint, config, poolSize); err != nil {
+			return err
+		}
+
+		s.Hosts = append(s.Hosts, db)
+
+	}
+
+	db = s.Hosts[0]
+
+	if query == "" {
+
+		if tables, err = gomulus.ListTables(db, dialect, database); err != nil {
+			return err
+		}
+
+		if len(include) > 0 {
+
+			if tables, err = MatchTables(tables, include, exclude); err != nil {
+				return err
+			}
+
+			if len(tables) == 0 {
+				return fmt.Errorf("no table matching `%s` found in `%s`", strings.Join(include, "`, `"), database)
+			}
+
+			s.Tables = tables
+
+		} else if !gomulus.InSliceString(table, tables) {
+			return fmt.Errorf("table not found `%s`.`%s`", database, table)
+		}
+
+	}
+
+	s.DB = db
+	s.Dialect = dialect
+	s.Table = table
+	s.Database = database
+	s.Where = where
+	s.Query = query
+	s.Columns = selected
+	s.SplitBy = splitBy
+	s.SplitMode = splitMode
+	s.PostSQL = postSQL
+	s.Limit = int(math.Max(1, limit))
+	s.Offset = int(math.Max(0, offset))
+
+	return nil
+
+}
+
+// prepare bounds the rows by the watermark, counts them and reads the schema and primary key
+// of the table or query, or of every table in whole database mode.
+func (s *DefaultSQLSource) prepare(config map[string]interface{}) error {
+
+	var err error
+	var count, _ = config["count"].(float64)
+	var watermarkColumn, _ = config["watermark_column"].(string)
+	var stateFile, _ = config["state_file"].(string)
+	var stateKey, _ = config["state_key"].(string)
+
+	if watermarkColumn != "" {
+
+		if stateFile == "" {
+			stateFile = "./gomulus.state.json"
+		}
+
+		if stateKey == "" && s.Query != "" {
+			stateKey = fmt.Sprintf("query-%x.%s", sha1.Sum([]byte(s.Query)), watermarkColumn)
+		} else if stateKey == "" {
+			stateKey = fmt.Sprintf("%s.%s.%s", s.Database, s.Table, watermarkColumn)
+		}
+
+		s.Watermark = Watermark{Column: watermarkColumn, StateFile: stateFile, StateKey: stateKey}
+
+		if err = s.setupWatermark(config["watermark_overlap"]); err != nil {
+			return err
+		}
+
+	}
+
+	if count == 0 && s.SplitBy == "" && len(s.Tables) == 0 {
+		if err = s.querier().QueryRowContext(context.Background(), fmt.Sprintf("SELECT COUNT(*) FROM %s%s", s.from(), s.where()), s.args()...).Scan(&count); err != nil {
+			return err
+		}
+	}
+
+	s.Count = int(math.Max(1, count))
+
+	if len(s.Tables) == 0 {
+
+		if s.Schema, err = s.readSchema(); err != nil {
+			return err
+		}
+
+		if s.Query == "" {
+			if s.PrimaryKey, err = s.readPrimaryKey(); err != nil {
+				return err
+			}
+		}
+
+		return nil
+
+	}
+
+	if s.Tables, s.References, err = s.sortTables(s.Tables); err != nil {
+		return err
+	}
+
+	s.Schemas = make(map[string]gomulus.Schema, len(s.Tables))
+	s.PrimaryKeys = make(map[string][]string, len(s.Tables))
+
+	for _, table := range s.Tables {
+
+		t := s.tableSource(table)
+
+		if s.Schemas[table], err = t.readSchema(); err != nil {
+			return fmt.Errorf("table `%s`: %s", table, err.Error())
+		}
+
+		if s.PrimaryKeys[table], err = t.readPrimaryKey(); err != nil {
+			return fmt.Errorf("table `%s`: %s", table, err.Error())
+		}
+
+	}
+
+	return nil
+
+}
+
+// release closes the connections of every host, when New fails.
+func (s *DefaultSQLSource) release() {
+
+	for _, host := range s.Hosts {
+		_ = host.Close()
+	}
+
+	s.Hosts = nil
+
+}
+
+func (s *DefaultSQLSource) GetSchema() (gomulus.Schema, error) {
+
+	return s.Schema, nil
+
+}
+
+func (s *DefaultSQLSource) GetTableSchema(table string) (gomulus.Schema, error) {
+
+	schema, ok := s.Schemas[table]
+
+	if !ok {
+		return gomulus.Schema{}, fmt.Errorf("table `%s` is not exported", table)
+	}
+
+	return schema, nil
+
+}
+
+// readSchema returns the schema of the selected columns, by the schema reader when set,
+// else by the Go types the driver scans them into.
+func (s *DefaultSQLSource) readSchema() (gomulus.Schema, error) {
+
+	if s.SchemaReader != nil {
+		return s.SchemaReader.ReadSchema(s)
+	}
+
+	rows, err := s.querier().QueryContext(context.Background(), s.Dialect.Paginate(fmt.Sprintf("SELECT %s FROM %s", s.Columns, s.from()), 1, 0))
+
+	if err != nil {
+		return gomulus.Schema{}, err
+	}
+
+	defer rows.Close()
+
+	types, err := rows.ColumnTypes()
+
+	if err != nil {
+		return gomulus.Schema{}, err
+	}
+
+	return SQLSchema(types), nil
+
+}
+
+// readPrimaryKey returns the columns of the primary key of the table, in order, none lacking one.
+func (s *DefaultSQLSource) readPrimaryKey() ([]string, error) {
+
+	columns, err := gomulus.PrimaryKey(s.querier(), s.Dialect, s.Database, s.Table)
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the primary key: %s", err.Error())
+	}
+
+	return columns, nil
+
+}
+
+// GetJobs returns every job at once, so tables are not waited for the ones they reference.
+func (s *DefaultSQLSource) GetJobs() ([]map[string]interface{}, error) {
+
+	t := *s
+	t.References = nil
+
+	return gomulus.CollectJobs(t.IterateJobs)
+
+}
+
+func (s *DefaultSQLSource) IterateJobs(jobs chan<- map[string]interface{}) error {
+
+	if len(s.Tables) > 0 {
+		return s.iterateTableJobs(jobs)
+	}
+
+	if s.SplitBy != "" && s.SplitMode == "adaptive" {
+		return s.iterateAdaptiveJobs(jobs)
+	}
+
+	if s.SplitBy != "" {
+		return s.iterateRangeJobs(jobs)
+	}
+
+	offset := s.Offset
+
+	for true {
+
+		if offset > s.Count-1 {
+			break
+		}
+
+		query := s.Dialect.Paginate(fmt.Sprintf("SELECT %s FROM %s%s%s", s.Columns, s.from(), s.where(), s.orderBy()), s.Limit, offset)
+
+		offset += s.Limit
+
+		jobs <- map[string]interface{}{
+			"query": query,
+			"args":  s.args(),
+		}
+
+	}
+
+	return nil
+
+}
+
+// iterateTableJobs generates the jobs of every table in turn, tagged with the table name.
+// Tables lacking the split_by column are paginated by offset. The jobs of a table are only
+// generated once the jobs of the tables it references have all been acknowledged.
+func (s *DefaultSQLSource) iterateTableJobs(jobs chan<- map[string]interface{}) error {
+
+	s.Progress = NewTableProgress()
+
+	for _, table := range s.Tables {
+
+		// tables of a reference cycle are not waited for
+		for _, referenced := range s.References[table] {
+			s.Progress.Wait(referenced)
+		}
+
+		t := s.tableSource(table)
+
+		if t.SplitBy != "" && t.Schema.Index(t.SplitBy) < 0 {
+			t.SplitBy = ""
+		}
+
+		if t.SplitBy == "" {
+
+			var count float64
+
+			if err := t.querier().QueryRowContext(context.Background(), fmt.Sprintf("SELECT COUNT(*) FROM %s%s", t.from(), t.where()), t.args()...).Scan(&count); err != nil {
+				return fmt.Errorf("table `%s`: %s", table, err.Error())
+			}
+
+			t.Count = int(math.Max(1, count))
+
+		}
+
+		tableJobs := make(chan map[string]interface{})
+		done := make(chan error, 1)
+
+		go func() {
+			done <- t.IterateJobs(tableJobs)
+			close(tableJobs)
+		}()
+
+		for job := range tableJobs {
+			job["table"] = table
+			s.Progress.Add(table)
+			jobs <- job
+		}
+
+		s.Progress.Finish(table)
+
+		if err := <-done; err != nil {
+			return fmt.Errorf("table `%s`: %s", table, err.Error())
+		}
+
+	}
+
+	return nil
+
+}
+
+// Acknowledge counts the persisted jobs of every table, releasing the tables referencing it.
+func (s *DefaultSQLSource) Acknowledge(job map[string]interface{}) error {
+
+	if table, ok := job["table"].(string); ok && s.Progress != nil {
+		s.Progress.Acknowledge(table)
+	}
+
+	return nil
+
+}
+
+// AcknowledgeFailure releases the tables referencing the one of a failed job as well,
+// the run being failed anyway.
+func (s *DefaultSQLSource) AcknowledgeFailure(job map[string]interface{}) {
+
+	_ = s.Acknowledge(job)
+
+}
+
+func NewTableProgress() *TableProgress {
+
+	return &TableProgress{
+		Pending:   make(map[string]int, 0),
+		Generated: make(map[string]bool, 0),
+		Done:      make(map[string]chan struct{}, 0),
+	}
+
+}
+
+func (p *TableProgress) done(table string) chan struct{} {
+
+	if p.Done[table] == nil {
+		p.Done[table] = make(chan struct{})
+	}
+
+	return p.Done[table]
+
+}
+
+func (p *TableProgress) Add(table string) {
+
+	p.Mutex.Lock()
+	p.Pending[table]++
+	p.Mutex.Unlock()
+
+}
+
+// Finish marks the jobs of the table as all generated, done once acknowledged.
+func (p *TableProgress) Finish(table string) {
+
+	p.Mutex.Lock()
+	defer p.Mutex.Unlock()
+
+	p.Generated[table] = true
+
+	if p.Pending[table] == 0 {
+		close(p.done(table))
+	}
+
+}
+
+func (p *TableProgress) Acknowledge(table string) {
+
+	p.Mutex.Lock()
+	defer p.Mutex.Unlock()
+
+	p.Pending[table]--
+
+	if p.Pending[table] == 0 && p.Generated[table] {
+		close(p.done(table))
+	}
+
+}
+
+// Wait returns once every job of the table is acknowledged, or at once when the table
+// is not generated yet, as in a reference cycle.
+func (p *TableProgress) Wait(table string) {
+
+	p.Mutex.Lock()
+
+	if !p.Generated[table] {
+		p.Mutex.Unlock()
+		return
+	}
+
+	done := p.done(table)
+
+	p.Mutex.Unlock()
+
+	<-done
+
+}
+
+// tableSource returns a copy of the source reading a single table of the database.
+func (s *DefaultSQLSource) tableSource(table string) *DefaultSQLSource {
+
+	t := *s
+	t.Table = table
+	t.Tables = nil
+	t.Schema = s.Schemas[table]
+	t.PrimaryKey = s.PrimaryKeys[table]
+
+	return &t
+
+}
+
+// sortTables orders the tables by foreign key dependency, referenced tables first, and returns
+// the tables referenced by each one, so that destinations enforcing foreign keys can be loaded
+// in order. Tables in a reference cycle keep their relative order, as all of them do when the
+// dialect can't tell the foreign keys.
+func (s *DefaultSQLSource) sortTables(tables []string) ([]string, map[string][]string, error) {
+
+	var references = make(map[string][]string, 0)
+	var visited = make(map[string]bool, 0)
+	var sorted = make([]string, 0, len(tables))
+	var visit func(string)
+
+	keys, ok := s.Dialect.(gomulus.KeyDialectInterface)
+
+	if !ok {
+		return tables, references, nil
+	}
+
+	rows, err := gomulus.Select(s.querier(), keys.References(), s.Database)
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to read foreign keys: %s", err.Error())
+	}
+
+	for _, row := range rows {
+		table, referenced := fmt.Sprintf("%s", row[0]), fmt.Sprintf("%s", row[1])
+		if table != referenced && gomulus.InSliceString(referenced, tables) {
+			references[table] = append(references[table], referenced)
+		}
+	}
+
+	visit = func(table string) {
+		if visited[table] {
+			return
+		}
+		visited[table] = true
+		for _, referenced := range references[table] {
+			visit(referenced)
+		}
+		sorted = append(sorted, table)
+	}
+
+	for _, table := range tables {
+		visit(table)
+	}
+
+	return sorted, references, nil
+
+}
+
+// iterateRangeJobs splits the integer split_by column between its MIN and MAX values,
+// in ranges of `limit` keys.
+func (s *DefaultSQLSource) iterateRangeJobs(jobs chan<- map[string]interface{}) error {
+
+	var min, max sql.NullInt64
+
+	column := s.Dialect.QuoteIdentifier(s.SplitBy)
+	query := fmt.Sprintf("SELECT MIN(%s), MAX(%s) FROM %s%s", column, column, s.from(), s.where())
+
+	if err := s.querier().QueryRowContext(context.Background(), query, s.args()...).Scan(&min, &max); err != nil {
+		return fmt.Errorf("unable to get split_by `%s` boundaries, use split_mode `adaptive` for non integer columns: %s", s.SplitBy, err.Error())
+	}
+
+	if !min.Valid {
+		return nil
+	}
+
+	limit := int64(s.Limit)
+	selection := fmt.Sprintf("SELECT %s FROM %s", s.Columns, s.from())
+	lower := column + " >= " + s.placeholder(1)
+
+	for from := min.Int64; ; from += limit {
+
+		// checked before adding, as keys close to the int64 bounds would overflow
+		if from > math.MaxInt64-limit || from+limit > max.Int64 {
+			jobs <- map[string]interface{}{
+				"query": selection + s.where(lower, column+" <= "+s.placeholder(2)),
+				"args":  s.args(from, max.Int64),
+			}
+			return nil
+		}
+
+		jobs <- map[string]interface{}{
+			"query": selection + s.where(lower, column+" < "+s.placeholder(2)),
+			"args":  s.args(from, from+limit),
+		}
+
+	}
+
+}
+
+// iterateAdaptiveJobs walks the split_by column index, reading the key found every `limit` rows
+// as next boundary, so that sparse or non integer keys still produce even jobs.
+func (s *DefaultSQLSource) iterateAdaptiveJobs(jobs chan<- map[string]interface{}) error {
+
+	var from interface{}
+
+	column := s.Dialect.QuoteIdentifier(s.SplitBy)
+
+	if err := s.querier().QueryRowContext(context.Background(), fmt.Sprintf("SELECT MIN(%s) FROM %s%s", column, s.from(), s.where()), s.args()...).Scan(&from); err != nil {
+		return err
+	}
+
+	if from == nil {
+		return nil
+	}
+
+	lower := column + " >= " + s.placeholder(1)
+	upper := column + " < " + s.placeholder(2)
+	selection := fmt.Sprintf("SELECT %s FROM %s", s.Columns, s.from())
+	boundary := s.Dialect.Paginate(fmt.Sprintf("SELECT %s FROM %s%s ORDER BY %s", column, s.from(), s.where(lower), column), 1, s.Limit)
+	distinct := s.Dialect.Paginate(fmt.Sprintf("SELECT %s FROM %s%s ORDER BY %s", column, s.from(), s.where(column+" > "+s.placeholder(1)), column), 1, 0)
+
+	for {
+
+		var to interface{}
+
+		err := s.querier().QueryRowContext(context.Background(), boundary, s.args(from)...).Scan(&to)
+
+		// more than `limit` rows share the same key, move to the next one
+		if err == nil && s.sameKey(to, from) {
+			err = s.querier().QueryRowContext(context.Background(), distinct, s.args(from)...).Scan(&to)
+		}
+
+		if err == sql.ErrNoRows {
+			jobs <- map[string]interface{}{
+				"query": selection + s.where(lower),
+				"args":  s.args(from),
+			}
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		jobs <- map[string]interface{}{
+			"query": selection + s.where(lower, upper),
+			"args":  s.args(from, to),
+		}
+
+		from = to
+
+	}
+
+}
+
+// sameKey compares two split_by keys once converted by the column type, as drivers may read
+// MIN() through a text protocol while the boundary query, having arguments, is read as binary.
+func (s *DefaultSQLSource) sameKey(a interface{}, b interface{}) bool {
+
+	column := gomulus.Column{Name: s.SplitBy}
+
+	if i := s.Schema.Index(s.SplitBy); i >= 0 {
+		column = s.Schema.Columns[i]
+	}
+
+	a, err := gomulus.ConvertColumn(a, column)
+
+	if err != nil {
+		return false
+	}
+
+	b, err = gomulus.ConvertColumn(b, column)
+
+	if err != nil {
+		return false
+	}
+
+	return fmt.Sprint(a) == fmt.Sprint(b)
+
+}
+
+// querier returns the connection used to generate jobs.
+func (s *DefaultSQLSource) querier() gomulus.Querier {
+
+	if s.Meta != nil {
+		return s.Meta
+	}
+
+	return s.DB
+
+}
+
+// host returns the host of the next job.
+func (s *DefaultSQLSource) host() *sql.DB {
+
+	return s.Hosts[int(atomic.AddUint32(&s.NextHost, 1)-1)%len(s.Hosts)]
+
+}
+
+// from returns the table, or the custom query as a derived table.
+func (s *DefaultSQLSource) from() string {
+
+	if s.Query != "" {
+		return fmt.Sprintf("(%s) AS %s", s.Query, s.Dialect.QuoteIdentifier("gomulus_query"))
+	}
+
+	return gomulus.QualifiedName(s.Dialect, s.Database, s.Table)
+
+}
+
+// where returns the WHERE clause joining the `where` option to the given conditions.
+func (s *DefaultSQLSource) where(conditions ...string) string {
+
+	conditions = append(append([]string{}, s.Conditions...), conditions...)
+
+	if s.Where != "" {
+		conditions = append([]string{"(" + s.Where + ")"}, conditions...)
+	}
+
+	if len(conditions) == 0 {
+		return ""
+	}
+
+	return " WHERE " + strings.Join(conditions, " AND ")
+
+}
+
+// placeholder returns the placeholder of the given argument following the ones of the WHERE clause.
+func (s *DefaultSQLSource) placeholder(position int) string {
+
+	return s.Dialect.Placeholder(len(s.WhereArgs) + position)
+
+}
+
+// orderBy returns the ORDER BY clause of the jobs paginated by offset, by the primary key.
+func (s *DefaultSQLSource) orderBy() string {
+
+	if len(s.PrimaryKey) == 0 {
+		return ""
+	}
+
+	columns := make([]string, 0, len(s.PrimaryKey))
+
+	for _, column := range s.PrimaryKey {
+		columns = append(columns, s.Dialect.QuoteIdentifier(column))
+	}
+
+	return " ORDER BY " + strings.Join(columns, ", ")
+
+}
+
+// DeterministicJobs is true when every job selects the same rows on every run of unchanged tables:
+// jobs split by a column, or paginated by offset in the order of a primary key. Jobs bounded by
+// the high-water mark change with it on every run.
+func (s *DefaultSQLSource) DeterministicJobs() bool {
+
+	if s.Watermark.Column != "" {
+		return false
+	}
+
+	if len(s.Tables) == 0 {
+		return s.SplitBy != "" || len(s.PrimaryKey) > 0
+	}
+
+	for _, table := range s.Tables {
+		if t := s.tableSource(table); t.Schema.Index(t.SplitBy) < 0 && len(t.PrimaryKey) == 0 {
+			return false
+		}
+	}
+
+	return true
+
+}
+
+// args returns the arguments of the WHERE clause followed by the given ones.
+func (s *DefaultSQLSource) args(args ...interface{}) []interface{} {
+
+	return append(append([]interface{}{}, s.WhereArgs...), args...)
+
+}
+
+// setupWatermark restricts the run to the rows above the high-water mark persisted by the last
+// successful run, minus the overlap, and up to the current one, persisted by Close on success.
+func (s *DefaultSQLSource) setupWatermark(overlap interface{}) error {
+
+	var err error
+	var low string
+	var high interface{}
+
+	column := s.Dialect.QuoteIdentifier(s.Watermark.Column)
+
+	if low, err = gomulus.LoadState(s.Watermark.StateFile, s.Watermark.StateKey); err != nil {
+		return fmt.Errorf("unable to read watermark state: %s", err.Error())
+	}
+
+	t := *s
+	t.Columns = column
+
+	schema, err := t.readSchema()
+
+	if err != nil {
+		return err
+	}
+
+	if len(schema.Columns) == 0 {
+		return fmt.Errorf("watermark_column `%s` not found", s.Watermark.Column)
+	}
+
+	logical := schema.Columns[0].Type
+
+	// dates and times lacking a timezone are in the one of the source
+	location, err := schema.Columns[0].Location()
+
+	if err != nil {
+		return err
+	}
+
+	if err = s.querier().QueryRowContext(context.Background(), fmt.Sprintf("SELECT MAX(%s) FROM %s%s", column, s.from(), s.where()), s.args()...).Scan(&high); err != nil {
+		return err
+	}
+
+	if high == nil {
+		s.Conditions = append(s.Conditions, "FALSE")
+		return nil
+	}
+
+	if low != "" {
+
+		from, err := WatermarkLowerBound(low, logical, overlap, location)
+
+		if err != nil {
+			return err
+		}
+
+		s.Conditions = append(s.Conditions, column+" > "+s.placeholder(1))
+		s.WhereArgs = append(s.WhereArgs, from)
+
+	}
+
+	s.Conditions = append(s.Conditions, column+" <= "+s.placeholder(1))
+	s.WhereArgs = append(s.WhereArgs, high)
+
+	s.Watermark.High, err = FormatWatermark(high, logical, location)
+
+	return err
+
+}
+
+// FormatWatermark returns the persisted representation of a high-water mark, dates and times
+// carrying the timezone offset when not in UTC.
+func FormatWatermark(high interface{}, logical gomulus.Type, location *time.Location) (string, error) {
+
+	value, err := gomulus.ConvertIn(high, logical, location)
+
+	if err != nil {
+		return "", err
+	}
+
+	if t, ok := value.(time.Time); ok && logical == gomulus.TypeDatetime {
+		value = t.In(location)
+	}
+
+	formatted, _ := gomulus.Format(value, logical)
+
+	return formatted, nil
+
+}
+
+// WatermarkLowerBound subtracts the overlap from the persisted watermark: a number for integer columns,
+// a duration such as `1h` or a number of seconds for date and time columns, which are returned in the
+// given location without timezone, as compared by the server.
+func WatermarkLowerBound(low string, logical gomulus.Type, overlap interface{}, location *time.Location) (interface{}, error) {
+
+	switch logical {
+
+	case gomulus.TypeInt, gomulus.TypeUint:
+
+		value, err := strconv.ParseInt(low, 10, 64)
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid watermark `%s`: %s", low, err.Error())
+		}
+
+		if overlap == nil {
+			return value, nil
+		}
+
+		amount, ok := overlap.(float64)
+
+		if !ok {
+			return nil, fmt.Errorf("invalid watermark_overlap `%v`, expected a number", overlap)
+		}
+
+		return value - int64(amount), nil
+
+	case gomulus.TypeDate, gomulus.TypeDatetime:
+
+		value, err := gomulus.ToTime(low, location)
+
+		if err != nil || value == nil {
+			return nil, fmt.Errorf("invalid watermark `%s`", low)
+		}
+
+		var duration time.Duration
+
+		switch v := overlap.(type) {
+		case nil:
+		case float64:
+			duration = time.Duration(v * float64(time.Second))
+		case string:
+			if duration, err = time.ParseDuration(v); err != nil {
+				return nil, fmt.Errorf("invalid watermark_overlap `%s`: %s", v, err.Error())
+			}
+		default:
+			return nil, fmt.Errorf("invalid watermark_overlap `%v`, expected a duration", overlap)
+		}
+
+		return value.(time.Time).Add(-duration).In(location).Format(gomulus.DatetimeLayout), nil
+
+	}
+
+	if overlap != nil {
+		return nil, errors.New("watermark_overlap is only supported by integer, date and time columns")
+	}
+
+	return low, nil
+
+}
+
+// Close persists the high-water mark of a successful run and runs the post_sql statements on every host.
+func (s *DefaultSQLSource) Close(success bool) error {
+
+	for _, host := range s.Hosts {
+		defer host.Close()
+	}
+
+	var err error
+
+	if success && s.Watermark.Column != "" && s.Watermark.High != "" {
+		err = gomulus.SaveState(s.Watermark.StateFile, s.Watermark.StateKey, s.Watermark.High)
+	}
+
+	for _, host := range s.Hosts {
+		if e := gomulus.ExecStatements(host, "post_sql", s.PostSQL); e != nil && err == nil {
+			err = e
+		}
+	}
+
+	return err
+
+}
+
+func (s *DefaultSQLSource) FetchData(meta map[string]interface{}) ([][]interface{}, error) {
+
+	var data = make([][]interface{}, 0)
+
+	err := s.fetch(meta, func(row []interface{}) error {
+		data = append(data, row)
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return data, nil
+
+}
+
+func (s *DefaultSQLSource) StreamData(meta map[string]interface{}, rows chan<- []interface{}) error {
+
+	return s.fetch(meta, func(row []interface{}) error {
+		rows <- row
+		return nil
+	})
+
+}
+
+// fetch selects the rows of the job on the next host, converting the values by the column types.
+func (s *DefaultSQLSource) fetch(meta map[string]interface{}, callback func([]interface{}) error) error {
+
+	var schema = s.Schema
+	var query, _ = meta["query"].(string)
+	var args, _ = meta["args"].([]interface{})
+
+	if table, ok := meta["table"].(string); ok {
+		schema = s.Schemas[table]
+	}
+
+	return gomulus.SelectEach(s.host(), query, args, func(row []interface{}) error {
+
+		if _, err := gomulus.ConvertRow(row, schema); err != nil {
+			return err
+		}
+
+		return callback(row)
+
+	})
+
+}
+
+// SQLSchema describes the columns by the Go types their driver scans them into.
+func SQLSchema(types []*sql.ColumnType) gomulus.Schema {
+
+	schema := gomulus.Schema{Columns: make([]gomulus.Column, 0, len(types))}
+
+	for _, t := range types {
+
+		column := gomulus.Column{Name: t.Name(), Type: gomulus.TypeString, DatabaseType: t.DatabaseTypeName()}
+		column.Nullable, _ = t.Nullable()
+
+		if length, ok := t.Length(); ok && length < math.MaxInt32 {
+			column.Length = int(length)
+		}
+
+		scanType := t.ScanType()
+
+		switch {
+		case scanType == nil:
+		case scanType == reflect.TypeOf(time.Time{}), scanType == reflect.TypeOf(sql.NullTime{}):
+			column.Type = gomulus.TypeDatetime
+		case scanType == reflect.TypeOf(sql.NullInt64{}), scanType == reflect.TypeOf(sql.NullInt32{}):
+			column.Type = gomulus.TypeInt
+		case scanType == reflect.TypeOf(sql.NullFloat64{}):
+			column.Type = gomulus.TypeFloat
+		case scanType == reflect.TypeOf(sql.NullBool{}):
+			column.Type = gomulus.TypeBool
+		default:
+			switch scanType.Kind() {
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+				column.Type = gomulus.TypeInt
+			case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+				column.Type = gomulus.TypeUint
+			case reflect.Float32, reflect.Float64:
+				column.Type = gomulus.TypeFloat
+			case reflect.Bool:
+				column.Type = gomulus.TypeBool
+			}
+		}
+
+		if precision, scale, ok := t.DecimalSize(); ok {
+			column.Type = gomulus.TypeDecimal
+			column.Precision = int(precision)
+			column.Scale = int(scale)
+		}
+
+		schema.Columns = append(schema.Columns, column)
+
+	}
+
+	return schema
+
+}
+
+// MatchTables returns the tables matching any of the include glob patterns and none of the exclude ones.
+func MatchTables(tables []string, include []string, exclude []string) ([]string, error) {
+
+	var matching = make([]string, 0)
+
+	for _, table := range tables {
+
+		included, err := MatchAny(table, include)
+
+		if err != nil {
+			return nil, err
+		}
+
+		excluded, err := MatchAny(table, exclude)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if included && !excluded {
+			matching = append(matching, table)
+		}
+
+	}
+
+	return matching, nil
+
+}
+
+func MatchAny(name string, patterns []string) (bool, error) {
+
+	for _, pattern := range patterns {
+
+		ok, err := path.Match(pattern, name)
+
+		if err != nil {
+			return false, fmt.Errorf("invalid table pattern `%s`", pattern)
+		}
+
+		if ok {
+			return true, nil
+		}
+
+	}
+
+	return false, nil
+
+}
@@ -0,0 +1,550 @@
+package gomulus
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"gomulus"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSQLSource(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		database string
+		columns  interface{}
+		expected string
+		fails    string
+	}{
+		{"every column", "shop", nil, "SELECT * FROM `shop`.`orders` ORDER BY `id` LIMIT 0, 10", ""},
+		{"quoted list", "shop", []interface{}{"id", "na`me"}, "SELECT `id`, `na``me` FROM `shop`.`orders` ORDER BY `id` LIMIT 0, 10", ""},
+		{"expressions as they are", "shop", "id, UPPER(name) AS name", "SELECT id, UPPER(name) AS name FROM `shop`.`orders` ORDER BY `id` LIMIT 0, 10", ""},
+		{"no database", "", nil, "", "invalid database name ``"},
+	}
+
+	for _, test := range tests {
+
+		t.Run(strings.Replace(test.name, " ", "_", -1), func(t *testing.T) {
+
+			openFake(t, func(query string, args []driver.Value) (*fakeResult, error) {
+				switch {
+				case strings.HasPrefix(query, "SHOW TABLES"):
+					return &fakeResult{columns: []string{"table"}, rows: [][]driver.Value{{"orders"}}}, nil
+				case strings.HasPrefix(query, "SELECT COUNT(*)"):
+					return &fakeResult{columns: []string{"count"}, rows: [][]driver.Value{{int64(2)}}}, nil
+				case strings.Contains(query, "KEY_COLUMN_USAGE"):
+					return &fakeResult{columns: []string{"COLUMN_NAME"}, rows: [][]driver.Value{{[]byte("id")}}}, nil
+				}
+				return &fakeResult{columns: []string{"id", "name"}, rows: [][]driver.Value{{[]byte("1"), []byte("first")}}}, nil
+			})
+
+			s := &DefaultSQLSource{}
+
+			err := s.New(map[string]interface{}{
+				"driver":   "gomulus-fake",
+				"dialect":  "mysql",
+				"host":     t.Name(),
+				"database": test.database,
+				"table":    "orders",
+				"columns":  test.columns,
+				"limit":    float64(10),
+			})
+
+			if test.fails != "" {
+				if err == nil || err.Error() != test.fails {
+					t.Errorf("expected error `%s`, got %v", test.fails, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer s.Close(true)
+
+			jobs, err := s.GetJobs()
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(jobs) != 1 || jobs[0]["query"] != test.expected {
+				t.Fatalf("expected a single job `%s`, got %v", test.expected, jobs)
+			}
+
+			rows, err := s.FetchData(jobs[0])
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// the raw values of the driver are converted by the schema
+			if expected := [][]interface{}{{"1", "first"}}; !reflect.DeepEqual(rows, expected) {
+				t.Errorf("expected rows %#v, got %#v", expected, rows)
+			}
+
+		})
+
+	}
+
+}
+
+func TestWhere(t *testing.T) {
+
+	tests := []struct {
+		name       string
+		where      string
+		conditions []string
+		extra      []string
+		expected   string
+	}{
+		{"nothing", "", nil, nil, ""},
+		{"option only", "a = 1 OR b = 2", nil, nil, " WHERE (a = 1 OR b = 2)"},
+		{"conditions only", "", []string{"`c` > ?"}, []string{"`id` >= ?"}, " WHERE `c` > ? AND `id` >= ?"},
+		{"option first", "a = 1 OR b = 2", []string{"`c` <= ?"}, []string{"`id` >= ?", "`id` < ?"}, " WHERE (a = 1 OR b = 2) AND `c` <= ? AND `id` >= ? AND `id` < ?"},
+	}
+
+	for _, test := range tests {
+
+		s := &DefaultSQLSource{Where: test.where, Conditions: test.conditions}
+
+		if actual := s.where(test.extra...); actual != test.expected {
+			t.Errorf("%s: expected `%s`, got `%s`", test.name, test.expected, actual)
+		}
+
+		// the conditions of the source are never modified
+		if len(s.Conditions) != len(test.conditions) {
+			t.Errorf("%s: conditions changed to %v", test.name, s.Conditions)
+		}
+
+	}
+
+}
+
+func TestIterateRangeJobs(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		min, max interface{}
+		limit    int
+		expected [][]interface{}
+	}{
+		{"empty table", nil, nil, 10, [][]interface{}{}},
+		{"single key", int64(5), int64(5), 10, [][]interface{}{{int64(5), int64(5)}}},
+		{"single range", int64(1), int64(10), 10, [][]interface{}{{int64(1), int64(10)}}},
+		{"exact ranges", int64(1), int64(20), 10, [][]interface{}{{int64(1), int64(11)}, {int64(11), int64(20)}}},
+		{"upper bound reached", int64(0), int64(20), 10, [][]interface{}{{int64(0), int64(10)}, {int64(10), int64(20)}, {int64(20), int64(20)}}},
+		{"negative keys", int64(-15), int64(3), 10, [][]interface{}{{int64(-15), int64(-5)}, {int64(-5), int64(3)}}},
+		{"int64 bounds", int64(math.MinInt64), int64(math.MaxInt64), math.MaxInt64, [][]interface{}{{int64(math.MinInt64), int64(-1)}, {int64(-1), int64(math.MaxInt64 - 1)}, {int64(math.MaxInt64 - 1), int64(math.MaxInt64)}}},
+	}
+
+	for _, test := range tests {
+
+		t.Run(strings.Replace(test.name, " ", "_", -1), func(t *testing.T) {
+
+			db := openFake(t, func(query string, args []driver.Value) (*fakeResult, error) {
+				if !strings.HasPrefix(query, "SELECT MIN(`id`), MAX(`id`) FROM `shop`.`orders` WHERE (`status` = 1)") {
+					return nil, fmt.Errorf("unexpected query %s", query)
+				}
+				return &fakeResult{columns: []string{"min", "max"}, rows: [][]driver.Value{{test.min, test.max}}}, nil
+			})
+
+			s := &DefaultSQLSource{DB: db, Dialect: mysqlDialect, Database: "shop", Table: "orders", Columns: "*", SplitBy: "id", Limit: test.limit, Where: "`status` = 1"}
+			jobs := collectJobs(t, s.iterateRangeJobs)
+
+			if len(jobs) != len(test.expected) {
+				t.Fatalf("expected %d jobs, got %v", len(test.expected), jobs)
+			}
+
+			for i, job := range jobs {
+
+				upper := "`id` < ?"
+
+				if i == len(jobs)-1 {
+					upper = "`id` <= ?"
+				}
+
+				if query := "SELECT * FROM `shop`.`orders` WHERE (`status` = 1) AND `id` >= ? AND " + upper; job["query"] != query {
+					t.Errorf("job %d: expected query `%s`, got `%s`", i, query, job["query"])
+				}
+
+				if !reflect.DeepEqual(job["args"], test.expected[i]) {
+					t.Errorf("job %d: expected args %v, got %v", i, test.expected[i], job["args"])
+				}
+
+			}
+
+		})
+
+	}
+
+}
+
+func TestIterateAdaptiveJobs(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		keys     []string
+		limit    int
+		expected [][]interface{}
+	}{
+		{"empty table", []string{}, 2, [][]interface{}{}},
+		{"single job", []string{"a", "b"}, 5, [][]interface{}{{"a"}}},
+		{"sparse keys", []string{"a", "c", "k", "x", "z"}, 2, [][]interface{}{{"a", "k"}, {"k", "z"}, {"z"}}},
+		{"repeated keys", []string{"a", "a", "a", "b", "c"}, 2, [][]interface{}{{"a", "b"}, {"b"}}},
+	}
+
+	for _, test := range tests {
+
+		t.Run(strings.Replace(test.name, " ", "_", -1), func(t *testing.T) {
+
+			keys := append([]string{}, test.keys...)
+			sort.Strings(keys)
+
+			db := openFake(t, func(query string, args []driver.Value) (*fakeResult, error) {
+
+				result := &fakeResult{columns: []string{"key"}}
+
+				switch {
+				case strings.HasPrefix(query, "SELECT MIN("):
+					var min driver.Value
+					if len(keys) > 0 {
+						min = keys[0]
+					}
+					result.rows = [][]driver.Value{{min}}
+				case strings.HasSuffix(query, fmt.Sprintf("`key` >= ? ORDER BY `key` LIMIT %d, 1", test.limit)):
+					from := args[0].(string)
+					i := sort.SearchStrings(keys, from) + test.limit
+					if i < len(keys) {
+						result.rows = [][]driver.Value{{keys[i]}}
+					}
+				case strings.HasSuffix(query, "`key` > ? ORDER BY `key` LIMIT 0, 1"):
+					from := args[0].(string)
+					i := sort.Search(len(keys), func(i int) bool { return keys[i] > from })
+					if i < len(keys) {
+						result.rows = [][]driver.Value{{keys[i]}}
+					}
+				default:
+					return nil, fmt.Errorf("unexpected query %s", query)
+				}
+
+				return result, nil
+
+			})
+
+			s := &DefaultSQLSource{DB: db, Dialect: mysqlDialect, Database: "shop", Table: "tags", Columns: "*", SplitBy: "key", SplitMode: "adaptive", Limit: test.limit}
+			jobs := collectJobs(t, s.iterateAdaptiveJobs)
+
+			if len(jobs) != len(test.expected) {
+				t.Fatalf("expected %d jobs, got %v", len(test.expected), jobs)
+			}
+
+			for i, job := range jobs {
+
+				args := job["args"].([]interface{})
+				actual := make([]interface{}, 0, len(args))
+
+				for _, arg := range args {
+					actual = append(actual, fmt.Sprintf("%s", arg))
+				}
+
+				if !reflect.DeepEqual(actual, test.expected[i]) {
+					t.Errorf("job %d: expected args %v, got %v", i, test.expected[i], actual)
+				}
+
+				if len(args) == 1 && job["query"] != "SELECT * FROM `shop`.`tags` WHERE `key` >= ?" {
+					t.Errorf("job %d: unexpected last query `%s`", i, job["query"])
+				}
+
+			}
+
+		})
+
+	}
+
+}
+
+// MIN() without arguments is read through the text protocol, the boundaries as binary values.
+func TestIterateAdaptiveJobsProtocols(t *testing.T) {
+
+	keys := []int64{1, 1, 1, 2, 3}
+
+	db := openFake(t, func(query string, args []driver.Value) (*fakeResult, error) {
+
+		result := &fakeResult{columns: []string{"id"}}
+
+		switch {
+		case strings.HasPrefix(query, "SELECT MIN("):
+			result.rows = [][]driver.Value{{[]byte("1")}}
+		case strings.HasSuffix(query, "`id` >= ? ORDER BY `id` LIMIT 2, 1"):
+			from, _ := gomulus.ToInt(args[0])
+			i := sort.Search(len(keys), func(i int) bool { return keys[i] >= from.(int64) }) + 2
+			if i < len(keys) {
+				result.rows = [][]driver.Value{{keys[i]}}
+			}
+		case strings.HasSuffix(query, "`id` > ? ORDER BY `id` LIMIT 0, 1"):
+			from, _ := gomulus.ToInt(args[0])
+			i := sort.Search(len(keys), func(i int) bool { return keys[i] > from.(int64) })
+			if i < len(keys) {
+				result.rows = [][]driver.Value{{keys[i]}}
+			}
+		default:
+			return nil, fmt.Errorf("unexpected query %s", query)
+		}
+
+		return result, nil
+
+	})
+
+	s := &DefaultSQLSource{DB: db, Dialect: mysqlDialect, Database: "shop", Table: "orders", Columns: "*", SplitBy: "id", SplitMode: "adaptive", Limit: 2}
+	s.Schema = gomulus.Schema{Columns: []gomulus.Column{{Name: "id", Type: gomulus.TypeInt}}}
+
+	jobs := collectJobs(t, s.iterateAdaptiveJobs)
+	expected := [][]interface{}{{"1", "2"}, {"2"}}
+
+	if len(jobs) != len(expected) {
+		t.Fatalf("expected %d jobs, got %v", len(expected), jobs)
+	}
+
+	for i, job := range jobs {
+
+		actual := make([]interface{}, 0)
+
+		for _, arg := range job["args"].([]interface{}) {
+			value, _ := gomulus.Format(arg, gomulus.TypeInt)
+			actual = append(actual, value)
+		}
+
+		if !reflect.DeepEqual(actual, expected[i]) {
+			t.Errorf("job %d: expected args %v, got %v", i, expected[i], actual)
+		}
+
+	}
+
+}
+
+func TestIterateOffsetJobs(t *testing.T) {
+
+	tests := []struct {
+		name       string
+		primaryKey []string
+		expected   []string
+	}{
+		{"no primary key", nil, []string{
+			"SELECT * FROM `shop`.`orders` LIMIT 0, 10",
+			"SELECT * FROM `shop`.`orders` LIMIT 10, 10",
+		}},
+		{"primary key", []string{"id"}, []string{
+			"SELECT * FROM `shop`.`orders` ORDER BY `id` LIMIT 0, 10",
+			"SELECT * FROM `shop`.`orders` ORDER BY `id` LIMIT 10, 10",
+		}},
+		{"composite primary key", []string{"shop_id", "id"}, []string{
+			"SELECT * FROM `shop`.`orders` ORDER BY `shop_id`, `id` LIMIT 0, 10",
+			"SELECT * FROM `shop`.`orders` ORDER BY `shop_id`, `id` LIMIT 10, 10",
+		}},
+	}
+
+	for _, test := range tests {
+
+		s := &DefaultSQLSource{Dialect: mysqlDialect, Database: "shop", Table: "orders", Columns: "*", PrimaryKey: test.primaryKey, Count: 15, Limit: 10}
+
+		jobs, err := gomulus.CollectJobs(s.IterateJobs)
+
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", test.name, err.Error())
+			continue
+		}
+
+		actual := make([]string, 0, len(jobs))
+
+		for _, job := range jobs {
+			actual = append(actual, job["query"].(string))
+		}
+
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("%s: expected queries %v, got %v", test.name, test.expected, actual)
+		}
+
+	}
+
+}
+
+func TestDeterministicJobs(t *testing.T) {
+
+	schema := gomulus.Schema{Columns: []gomulus.Column{{Name: "id"}, {Name: "created_at"}}}
+
+	tests := []struct {
+		name     string
+		source   DefaultSQLSource
+		expected bool
+	}{
+		{"table with primary key", DefaultSQLSource{Table: "orders", PrimaryKey: []string{"id"}}, true},
+		{"table without primary key", DefaultSQLSource{Table: "logs"}, false},
+		{"table split by a column", DefaultSQLSource{Table: "logs", SplitBy: "created_at"}, true},
+		{"query", DefaultSQLSource{Query: "SELECT * FROM logs"}, false},
+		{"query split by a column", DefaultSQLSource{Query: "SELECT * FROM logs", SplitBy: "id"}, true},
+		{"bounded by the watermark", DefaultSQLSource{Table: "orders", PrimaryKey: []string{"id"}, Watermark: Watermark{Column: "updated_at"}}, false},
+		{"tables with primary keys", DefaultSQLSource{
+			Tables:      []string{"orders", "customers"},
+			PrimaryKeys: map[string][]string{"orders": {"id"}, "customers": {"id"}},
+		}, true},
+		{"table without primary key nor split_by column", DefaultSQLSource{
+			Tables:      []string{"orders", "logs"},
+			Schemas:     map[string]gomulus.Schema{"logs": schema},
+			PrimaryKeys: map[string][]string{"orders": {"id"}},
+			SplitBy:     "updated_at",
+		}, false},
+		{"table split by its column", DefaultSQLSource{
+			Tables:      []string{"orders", "logs"},
+			Schemas:     map[string]gomulus.Schema{"logs": schema},
+			PrimaryKeys: map[string][]string{"orders": {"id"}},
+			SplitBy:     "created_at",
+		}, true},
+	}
+
+	for _, test := range tests {
+		if actual := test.source.DeterministicJobs(); actual != test.expected {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, actual)
+		}
+	}
+
+}
+
+func TestFormatWatermark(t *testing.T) {
+
+	rome, err := time.LoadLocation("Europe/Rome")
+
+	if err != nil {
+		t.Skip(err)
+	}
+
+	tests := []struct {
+		name     string
+		high     interface{}
+		logical  gomulus.Type
+		location *time.Location
+		expected string
+	}{
+		{"integer", []byte("1234"), gomulus.TypeInt, time.UTC, "1234"},
+		{"unsigned", uint64(18446744073709551615), gomulus.TypeUint, time.UTC, "18446744073709551615"},
+		{"datetime in UTC", []byte("2020-07-01 12:00:00"), gomulus.TypeDatetime, time.UTC, "2020-07-01 12:00:00"},
+		{"datetime text in zone", []byte("2020-07-01 12:00:00.5"), gomulus.TypeDatetime, rome, "2020-07-01 12:00:00.5+02:00"},
+		{"parsed datetime in zone", time.Date(2020, 1, 1, 11, 0, 0, 0, time.UTC), gomulus.TypeDatetime, rome, "2020-01-01 12:00:00+01:00"},
+		{"date in zone", []byte("2020-07-01"), gomulus.TypeDate, rome, "2020-07-01"},
+		{"string", []byte("v10"), gomulus.TypeString, time.UTC, "v10"},
+	}
+
+	for _, test := range tests {
+
+		actual, err := FormatWatermark(test.high, test.logical, test.location)
+
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", test.name, err.Error())
+		} else if actual != test.expected {
+			t.Errorf("%s: expected `%s`, got `%s`", test.name, test.expected, actual)
+		}
+
+	}
+
+}
+
+func TestWatermarkLowerBound(t *testing.T) {
+
+	rome, err := time.LoadLocation("Europe/Rome")
+
+	if err != nil {
+		t.Skip(err)
+	}
+
+	tests := []struct {
+		name     string
+		low      string
+		logical  gomulus.Type
+		overlap  interface{}
+		location *time.Location
+		expected interface{}
+		fails    bool
+	}{
+		{"integer", "100", gomulus.TypeInt, nil, time.UTC, int64(100), false},
+		{"integer overlap", "100", gomulus.TypeUint, float64(10), time.UTC, int64(90), false},
+		{"integer duration overlap", "100", gomulus.TypeInt, "1h", time.UTC, nil, true},
+		{"invalid integer", "1.5", gomulus.TypeInt, nil, time.UTC, nil, true},
+		{"datetime", "2020-07-01 12:00:00", gomulus.TypeDatetime, nil, time.UTC, "2020-07-01 12:00:00", false},
+		{"datetime duration overlap", "2020-07-01 12:00:00", gomulus.TypeDatetime, "15m", time.UTC, "2020-07-01 11:45:00", false},
+		{"datetime seconds overlap", "2020-07-01 12:00:00", gomulus.TypeDatetime, float64(90), time.UTC, "2020-07-01 11:58:30", false},
+		{"zoned datetime", "2020-07-01 12:00:00+02:00", gomulus.TypeDatetime, "1h", rome, "2020-07-01 11:00:00", false},
+		{"zoned datetime read in UTC", "2020-07-01 12:00:00+02:00", gomulus.TypeDatetime, nil, time.UTC, "2020-07-01 10:00:00", false},
+		{"datetime without zone", "2020-07-01 12:00:00", gomulus.TypeDatetime, nil, rome, "2020-07-01 12:00:00", false},
+		{"date", "2020-07-01", gomulus.TypeDate, "24h", time.UTC, "2020-06-30 00:00:00", false},
+		{"invalid overlap", "2020-07-01", gomulus.TypeDate, "a day", time.UTC, nil, true},
+		{"invalid datetime", "yesterday", gomulus.TypeDatetime, nil, time.UTC, nil, true},
+		{"string", "v10", gomulus.TypeString, nil, time.UTC, "v10", false},
+		{"string overlap", "v10", gomulus.TypeString, float64(1), time.UTC, nil, true},
+	}
+
+	for _, test := range tests {
+
+		actual, err := WatermarkLowerBound(test.low, test.logical, test.overlap, test.location)
+
+		if test.fails {
+			if err == nil {
+				t.Errorf("%s: expected an error, got %v", test.name, actual)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", test.name, err.Error())
+		} else if actual != test.expected {
+			t.Errorf("%s: expected %#v, got %#v", test.name, test.expected, actual)
+		}
+
+	}
+
+}
+
+func TestTableProgress(t *testing.T) {
+
+	progress := NewTableProgress()
+
+	// a table not generated yet, as in a reference cycle, is not waited for
+	progress.Wait("customers")
+
+	progress.Add("customers")
+	progress.Add("customers")
+	progress.Finish("customers")
+
+	released := make(chan bool)
+
+	go func() {
+		progress.Wait("customers")
+		close(released)
+	}()
+
+	progress.Acknowledge("customers")
+
+	select {
+	case <-released:
+		t.Fatal("released with a job not acknowledged")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	progress.Acknowledge("customers")
+
+	select {
+	case <-released:
+	case <-time.After(time.Second):
+		t.Fatal("not released once every job was acknowledged")
+	}
+
+	// a table without jobs is done as soon as generated
+	progress.Finish("empty")
+	progress.Wait("empty")
+
+}
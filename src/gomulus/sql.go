@@ -0,0 +1,413 @@
+package gomulus
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// sequence of the TLS configurations registered with the drivers
+var tlsConfigs uint64
+
+// Querier is implemented by *sql.DB, *sql.Conn and *sql.Tx.
+type Querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// OpenDB opens a database handle running the session statements on every new connection of its pool.
+func OpenDB(driverName string, dsn string, session []string) (*sql.DB, error) {
+
+	db, err := sql.Open(driverName, dsn)
+
+	if err != nil || len(session) == 0 {
+		return db, err
+	}
+
+	var connector driver.Connector = dsnConnector{DSN: dsn, Base: db.Driver()}
+
+	if base, ok := db.Driver().(driver.DriverContext); ok {
+		if connector, err = base.OpenConnector(dsn); err != nil {
+			return nil, err
+		}
+	}
+
+	_ = db.Close()
+
+	return sql.OpenDB(sessionConnector{Connector: connector, Statements: session}), nil
+
+}
+
+// Connect opens the database of the driver, connecting securely by the `tls` option when the dialect
+// supports it, and runs the `session_sql` and `pre_sql` statements, limiting the pool to size connections.
+func Connect(driverName string, dialect DialectInterface, dsn string, config map[string]interface{}, size int) (*sql.DB, error) {
+
+	tlsConfig, err := TLSConfig(config)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if tlsConfig != nil {
+
+		configurer, ok := dialect.(TLSDialectInterface)
+
+		if !ok {
+			return nil, fmt.Errorf("the `%s` driver doesn't support the tls option", driverName)
+		}
+
+		name := fmt.Sprintf("gomulus-%d", atomic.AddUint64(&tlsConfigs, 1))
+
+		if dsn, err = configurer.ConfigureTLS(dsn, name, tlsConfig); err != nil {
+			return nil, err
+		}
+
+	}
+
+	db, err := OpenDB(driverName, dsn, StringList(config["session_sql"]))
+
+	if err != nil {
+		return nil, err
+	}
+
+	ConfigurePool(db, config, size)
+
+	if err = ExecStatements(db, "pre_sql", StringList(config["pre_sql"])); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	return db, nil
+
+}
+
+// ExecStatements runs the statements in order, stopping at the first failing one.
+func ExecStatements(db *sql.DB, option string, statements []string) error {
+
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			return fmt.Errorf("%s statement `%s` failed: %s", option, statement, err.Error())
+		}
+	}
+
+	return nil
+
+}
+
+// ConfigurePool limits the connections of the pool by the max_open_conns, max_idle_conns and
+// conn_max_lifetime (milliseconds) options, max_open_conns defaulting to size, unlimited when 0.
+func ConfigurePool(db *sql.DB, config map[string]interface{}, size int) {
+
+	var maxOpen, open = config["max_open_conns"].(float64)
+	var maxIdle, idle = config["max_idle_conns"].(float64)
+	var lifetime, _ = config["conn_max_lifetime"].(float64)
+
+	if !open {
+		maxOpen = float64(size)
+	}
+
+	// idle connections are kept up to the open ones, instead of 2
+	if !idle && maxOpen > 0 {
+		maxIdle, idle = maxOpen, true
+	}
+
+	db.SetMaxOpenConns(int(maxOpen))
+
+	if idle {
+		db.SetMaxIdleConns(int(maxIdle))
+	}
+
+	if lifetime > 0 {
+		db.SetConnMaxLifetime(time.Duration(lifetime) * time.Millisecond)
+	}
+
+}
+
+// TLSConfig returns the TLS configuration of the `tls` option, nil without it: the PEM files of the
+// certificate authority (ca) and of the client certificate (cert, key), server_name and skip_verify.
+func TLSConfig(config map[string]interface{}) (*tls.Config, error) {
+
+	options, ok := config["tls"].(map[string]interface{})
+
+	if !ok {
+		return nil, nil
+	}
+
+	var ca, _ = options["ca"].(string)
+	var cert, _ = options["cert"].(string)
+	var key, _ = options["key"].(string)
+	var serverName, _ = options["server_name"].(string)
+	var skipVerify, _ = options["skip_verify"].(bool)
+
+	cfg := &tls.Config{ServerName: serverName, InsecureSkipVerify: skipVerify}
+
+	if ca != "" {
+
+		pem, err := ioutil.ReadFile(ca)
+
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the tls ca: %s", err.Error())
+		}
+
+		cfg.RootCAs = x509.NewCertPool()
+
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in the tls ca `%s`", ca)
+		}
+
+	}
+
+	if cert != "" || key != "" {
+
+		pair, err := tls.LoadX509KeyPair(cert, key)
+
+		if err != nil {
+			return nil, fmt.Errorf("unable to load the tls cert and key: %s", err.Error())
+		}
+
+		cfg.Certificates = []tls.Certificate{pair}
+
+	}
+
+	return cfg, nil
+
+}
+
+func Select(db Querier, query string, args ...interface{}) ([][]interface{}, error) {
+
+	slices := make([][]interface{}, 0)
+
+	err := SelectEach(db, query, args, func(row []interface{}) error {
+		slices = append(slices, row)
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return slices, nil
+
+}
+
+// SelectEach runs the query and calls back for every row, without holding the whole result in memory.
+func SelectEach(db Querier, query string, args []interface{}, callback func([]interface{}) error) error {
+
+	rows, err := db.QueryContext(context.Background(), query, args...)
+
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	columns, _ := rows.Columns()
+
+	for rows.Next() {
+
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+
+		for i := range columns {
+			pointers[i] = &values[i]
+		}
+
+		if err = rows.Scan(pointers...); err != nil {
+			return err
+		}
+
+		if err = callback(values); err != nil {
+			return err
+		}
+
+	}
+
+	return rows.Err()
+
+}
+
+// ExecRows runs the statement prepared in the transaction with every row, once converted,
+// stopping at the first failing one.
+func ExecRows(tx *sql.Tx, query string, data [][]interface{}, convert func([]interface{}) ([]interface{}, error)) error {
+
+	stmt, err := tx.Prepare(query)
+
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+
+	for _, row := range data {
+
+		if row, err = convert(row); err != nil {
+			return err
+		}
+
+		if _, err = stmt.Exec(row...); err != nil {
+			return err
+		}
+
+	}
+
+	return nil
+
+}
+
+// QualifiedName returns the quoted name of the table, within the database when given.
+func QualifiedName(dialect DialectInterface, database string, table string) string {
+
+	if database == "" {
+		return dialect.QuoteIdentifier(table)
+	}
+
+	return dialect.QuoteIdentifier(database) + "." + dialect.QuoteIdentifier(table)
+
+}
+
+// ListTables returns the tables of the database, by the query of the dialect.
+func ListTables(db *sql.DB, dialect DialectInterface, database string) ([]string, error) {
+
+	var tables = make([]string, 0)
+
+	rows, err := db.Query(dialect.ListTables(database))
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+
+		table := ""
+
+		if err = rows.Scan(&table); err != nil {
+			return nil, err
+		}
+
+		tables = append(tables, table)
+
+	}
+
+	return tables, rows.Err()
+
+}
+
+// PrimaryKey returns the columns of the primary key of the table in order, none when lacking one
+// or when the dialect can't tell.
+func PrimaryKey(db Querier, dialect DialectInterface, database string, table string) ([]string, error) {
+
+	keys, ok := dialect.(KeyDialectInterface)
+
+	if !ok {
+		return nil, nil
+	}
+
+	rows, err := Select(db, keys.PrimaryKey(), database, table)
+
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make([]string, 0, len(rows))
+
+	for _, row := range rows {
+		columns = append(columns, fmt.Sprintf("%s", row[0]))
+	}
+
+	return columns, nil
+
+}
+
+// InsertQuery returns the statement inserting a row of as many columns in the table, by position.
+func InsertQuery(dialect DialectInterface, database string, table string, columns int) string {
+
+	marks := make([]string, 0, columns)
+
+	for i := 1; i <= columns; i++ {
+		marks = append(marks, dialect.Placeholder(i))
+	}
+
+	return fmt.Sprintf("INSERT INTO %s VALUES (%s)", QualifiedName(dialect, database, table), strings.Join(marks, ", "))
+
+}
+
+// dsnConnector opens the connections of drivers lacking a connector of their own.
+type dsnConnector struct {
+	DSN  string
+	Base driver.Driver
+}
+
+func (c dsnConnector) Connect(ctx context.Context) (driver.Conn, error) {
+
+	return c.Base.Open(c.DSN)
+
+}
+
+func (c dsnConnector) Driver() driver.Driver {
+
+	return c.Base
+
+}
+
+type sessionConnector struct {
+	Connector  driver.Connector
+	Statements []string
+}
+
+func (c sessionConnector) Connect(ctx context.Context) (driver.Conn, error) {
+
+	conn, err := c.Connector.Connect(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, statement := range c.Statements {
+
+		if err = execConn(ctx, conn, statement); err != nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("session_sql statement `%s` failed: %s", statement, err.Error())
+		}
+
+	}
+
+	return conn, nil
+
+}
+
+func (c sessionConnector) Driver() driver.Driver {
+
+	return c.Connector.Driver()
+
+}
+
+func execConn(ctx context.Context, conn driver.Conn, statement string) error {
+
+	if execer, ok := conn.(driver.ExecerContext); ok {
+		if _, err := execer.ExecContext(ctx, statement, nil); err != driver.ErrSkip {
+			return err
+		}
+	}
+
+	stmt, err := conn.Prepare(statement)
+
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+
+	_, err = stmt.Exec(nil)
+
+	return err
+
+}
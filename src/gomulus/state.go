@@ -0,0 +1,81 @@
+package gomulus
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+var stateMutex sync.Mutex
+
+// LoadState returns the value stored under the given key of a JSON state file, empty when missing.
+func LoadState(path string, key string) (string, error) {
+
+	stateMutex.Lock()
+	defer stateMutex.Unlock()
+
+	state, err := readState(path)
+
+	if err != nil {
+		return "", err
+	}
+
+	return state[key], nil
+
+}
+
+// SaveState stores a value under the given key of a JSON state file, replacing the file atomically.
+func SaveState(path string, key string, value string) error {
+
+	stateMutex.Lock()
+	defer stateMutex.Unlock()
+
+	state, err := readState(path)
+
+	if err != nil {
+		return err
+	}
+
+	state[key] = value
+
+	content, err := json.MarshalIndent(state, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	temporary := path + ".tmp"
+
+	if err = ioutil.WriteFile(temporary, content, 0666); err != nil {
+		return err
+	}
+
+	return os.Rename(temporary, path)
+
+}
+
+func readState(path string) (map[string]string, error) {
+
+	var err error
+	var content []byte
+	var state = make(map[string]string, 0)
+
+	if path, err = filepath.Abs(path); err != nil {
+		return nil, err
+	}
+
+	if content, err = ioutil.ReadFile(path); os.IsNotExist(err) {
+		return state, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err = json.Unmarshal(content, &state); err != nil {
+		return nil, err
+	}
+
+	return state, nil
+
+}
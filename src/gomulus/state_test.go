@@ -0,0 +1,67 @@
+package gomulus
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestState(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "gomulus-state")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "state.json")
+
+	tests := []struct {
+		name  string
+		key   string
+		value string
+	}{
+		{"first key", "shop.orders.updated_at", "2020-07-01 12:00:00+02:00"},
+		{"second key", "shop.customers.id", "1234"},
+		{"replaced key", "shop.orders.updated_at", "2020-07-02 00:00:00+02:00"},
+		{"quoted value", "query-1a2b.id", `say "hi"`},
+	}
+
+	expected := make(map[string]string, 0)
+
+	if value, err := LoadState(path, "missing"); err != nil || value != "" {
+		t.Fatalf("missing file: expected an empty value, got `%s`, %v", value, err)
+	}
+
+	for _, test := range tests {
+
+		if err = SaveState(path, test.key, test.value); err != nil {
+			t.Fatalf("%s: %s", test.name, err.Error())
+		}
+
+		expected[test.key] = test.value
+
+		for key, value := range expected {
+			if actual, err := LoadState(path, key); err != nil || actual != value {
+				t.Errorf("%s: key `%s` expected `%s`, got `%s`, %v", test.name, key, value, actual, err)
+			}
+		}
+
+	}
+
+	if _, err = os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("the temporary file was left behind")
+	}
+
+	if err = ioutil.WriteFile(path, []byte("{"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = LoadState(path, "shop.customers.id"); err == nil {
+		t.Errorf("corrupted file: expected an error")
+	}
+
+}